@@ -73,31 +73,72 @@ func App() *buffalo.App {
 		app.Use(contenttype.Set("application/json"))
 		app.Use(popmw.Transaction(models.DB))
 
-		app.GET("/fake_auth/{login}", LoginAsUser)
+		// Password-less authentication, for demo purposes only
+		if ENV == "development" || ENV == "test" {
+			app.GET("/fake_auth/{login}", LoginAsUser)
+		}
 
 		// JWT authentication middleware
 		auth_mw := tokenAuth()
 
+		auth := app.Group("/auth")
+		auth.Use(auth_mw)
+		auth.POST("/login", AuthLogin)
+		auth.POST("/refresh", AuthRefresh)
+		auth.POST("/logout", AuthLogout)
+		auth.Middleware.Skip(auth_mw, AuthLogin, AuthRefresh)
+
+		// Authentication is optional on these ones
+		public_users := app.Group("/users")
+		public_users.Use(optionalTokenAuth(), enforceSanctions)
+		public_users.GET("/", UsersList)
+		public_users.POST("/", UsersCreate)
+
 		users := app.Group("/users")
-		users.Use(auth_mw)
-		users.GET("/", UsersList)
-		users.POST("/", UsersCreate)
+		users.Use(auth_mw, enforceSanctions)
 		users.GET("/{user_id}", UsersShow)
 		users.PUT("/{user_id}", UsersUpdate)
+		users.PATCH("/{user_id}", UsersPatch)
 		users.DELETE("/{user_id}", UsersDestroy)
 		users.POST("/{user_id}/friend_request", FriendRequestsCreate)
 		users.GET("/{user_id}/unfriend", FriendshipsDestroy)
+		users.GET("/{user_id}/friends", FriendshipsList)
+		users.GET("/{user_id}/mutual_friends", FriendshipsMutual)
+		users.GET("/{user_id}/path", FriendshipsPath)
 		users.POST("/{user_id}/report", ReportsCreate)
-		users.Middleware.Skip(auth_mw, UsersList, UsersCreate)
+		users.PUT("/{user_id}/roles/{role}", UsersGrantRole)
+		users.DELETE("/{user_id}/roles/{role}", UsersRevokeRole)
+		users.POST("/{user_id}/block", UsersBlock)
+		users.DELETE("/{user_id}/block", UsersUnblock)
+		users.POST("/{user_id}/sanctions", SanctionsCreate)
+
+		me := app.Group("/me")
+		me.Use(auth_mw, enforceSanctions)
+		me.GET("/suggestions", FriendSuggestionsList)
 
 		frs := app.Group("/friend_requests")
-		frs.Use(auth_mw)
+		frs.Use(auth_mw, enforceSanctions)
+		frs.GET("/", FriendRequestsList)
+		frs.DELETE("/{request_id}", FriendRequestsCancel)
 		frs.GET("/{request_id}/accept", FriendRequestsAccept)
 		frs.GET("/{request_id}/decline", FriendRequestsDecline)
 
 		reports := app.Group("/reports")
-		reports.Use(auth_mw)
+		reports.Use(auth_mw, enforceSanctions, requirePermission(models.PermReportsRead))
 		reports.GET("/", ReportsList)
+		reports.GET("/categories", ReportsCategories)
+		reports.GET("/queue", ReportsQueue)
+		reports.GET("/{report_id}", ReportsShow)
+		reports.PUT("/{report_id}/assign", ReportsAssign)
+		reports.PUT("/{report_id}/resolve", ReportsResolve)
+
+		sanctions := app.Group("/sanctions")
+		sanctions.Use(auth_mw, enforceSanctions)
+		sanctions.DELETE("/{sanction_id}", SanctionsLift)
+
+		audit_log := app.Group("/audit")
+		audit_log.Use(auth_mw, enforceSanctions, requirePermission(models.PermAuditRead))
+		audit_log.GET("/", AuditList)
 
 		app.GET("/swagger/{doc:.*}", buffaloSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -106,14 +147,37 @@ func App() *buffalo.App {
 		app.ErrorHandlers[403] = errorHandler()
 		app.ErrorHandlers[404] = errorHandler()
 		app.ErrorHandlers[409] = errorHandler()
+		app.ErrorHandlers[412] = errorHandler()
+		app.ErrorHandlers[428] = errorHandler()
+		app.ErrorHandlers[429] = errorHandler()
 		app.ErrorHandlers[500] = errorHandler()
 	}
 
 	return app
 }
 
+// tokenAuth validates the JWT passed in the Authorization header, checks
+// that it wasn't revoked, and loads the authenticated user.
 func tokenAuth() buffalo.MiddlewareFunc {
-	return tokenauth.New(tokenauth.Options{})
+	jwtAuth := tokenauth.New(tokenauth.Options{})
+	return func(next buffalo.Handler) buffalo.Handler {
+		return jwtAuth(rejectRevokedTokens(loadCurrentUser(next)))
+	}
+}
+
+// optionalTokenAuth authenticates the caller if a token is provided, and
+// lets anonymous requests through otherwise.
+func optionalTokenAuth() buffalo.MiddlewareFunc {
+	auth := tokenAuth()
+	return func(next buffalo.Handler) buffalo.Handler {
+		authenticated := auth(next)
+		return func(c buffalo.Context) error {
+			if c.Request().Header.Get("Authorization") == "" {
+				return next(c)
+			}
+			return authenticated(c)
+		}
+	}
 }
 
 // forceSSL will return a middleware that will redirect an incoming request
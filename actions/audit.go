@@ -0,0 +1,120 @@
+package actions
+
+import (
+	"net"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// audit records a privileged action performed by the caller. The event is
+// written in the request's transaction, so it is rolled back along with the
+// action itself if anything goes wrong.
+func audit(c buffalo.Context, tx *pop.Connection, event *models.AuditEvent) error {
+	event.ActorID = nulls.NewUUID(getCredentials(c).ID)
+	event.RequestID = requestID(c)
+	event.ClientRequestID = c.Request().Header.Get("X-Request-ID")
+	event.IP = clientIP(c)
+	return errors.WithStack(event.Create(tx))
+}
+
+// requestID returns the ID generated by buffalo for the current request.
+// Unlike the X-Request-ID header, it can't be chosen by the client.
+func requestID(c buffalo.Context) string {
+	id, _ := c.Value("request_id").(string)
+	return id
+}
+
+// clientIP returns the IP address the current request comes from
+func clientIP(c buffalo.Context) string {
+	addr := c.Request().RemoteAddr
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// AuditList lists the audit events
+// @Summary List audit events (requires "audit:read" permission)
+// @Description List the trace of privileged actions, most recent first.
+// @Description Audit events can't be modified nor deleted.
+// @security Bearer
+// @Produce  json
+// @Param actor_id query string false "Only list actions performed by this user"
+// @Param action query string false "Only list this kind of action" Enums(user.update, user.promote, user.delete, user.grant_role, user.revoke_role, sanction.create, sanction.lift, reports.read)
+// @Param target_type query string false "Only list actions on this kind of object" Enums(user, report, sanction)
+// @Param target_id query string false "Only list actions on this object"
+// @Param created_after query string false "Only list actions performed after this date (RFC 3339)"
+// @Param created_before query string false "Only list actions performed before this date (RFC 3339)"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} models.AuditEvents
+// @Header 200  {object} X-Pagination "pagination information"
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Router /audit/ [get]
+func AuditList(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	q := tx.PaginateFromParams(c.Params())
+	for _, field := range []string{"action", "target_type"} {
+		if value := c.Param(field); value != "" {
+			q = q.Where(field+" = ?", value)
+		}
+	}
+	for _, field := range []string{"actor_id", "target_id"} {
+		value := c.Param(field)
+		if value == "" {
+			continue
+		}
+		id, err := uuid.FromString(value)
+		if err != nil {
+			return c.Error(400, errors.Errorf("Invalid %s: %s", field, value))
+		}
+		q = q.Where(field+" = ?", id)
+	}
+	q, err := filterCreated(c, q, "audit_events")
+	if err != nil {
+		return err
+	}
+
+	events := &models.AuditEvents{}
+	if err := q.Order("created_at desc, id").All(events); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("pagination", q.Paginator)
+	return c.Render(200, r.JSON(events))
+}
+
+// snapshotUser copies a user before it gets modified, so that the changes
+// can be audited afterwards.
+func snapshotUser(u *models.User) *models.User {
+	s := *u
+	s.Roles = append([]models.Role(nil), u.Roles...)
+	return &s
+}
+
+// auditUser records a privileged action on a user, along with the changes
+// it made. The user after the action is nil if it was deleted.
+func auditUser(c buffalo.Context, tx *pop.Connection, action string, before, after *models.User) error {
+	changes, err := models.Diff(before, after)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return audit(c, tx, &models.AuditEvent{
+		Action:     action,
+		TargetType: models.AuditTargetUser,
+		TargetID:   nulls.NewUUID(before.ID),
+		Changes:    changes,
+	})
+}
@@ -0,0 +1,87 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+)
+
+// auditEvents lists the audit events matching given query string
+func (as *ActionSuite) auditEvents(token, query string) models.AuditEvents {
+	resp := as.createAuthRequest("/audit?"+query, token).Get()
+	as.Equal(200, resp.Code)
+	events := models.AuditEvents{}
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &events))
+	return events
+}
+
+func (as *ActionSuite) Test_Audit() {
+	admin, admin_token := as.createUserAndToken(true)
+	user, user_token := as.createUserAndToken(false)
+	_, moderator_token := as.createUserWithRole(models.RoleModerator)
+	victim := as.createRandomUser()
+
+	// Only admins can read the audit log
+	resp := as.createAuthRequest("/audit", user_token).Get()
+	as.Equal(403, resp.Code)
+	resp = as.createAuthRequest("/audit", moderator_token).Get()
+	as.Equal(403, resp.Code)
+
+	// Users editing their own profile aren't audited
+	resp = as.createUpdateRequest(fmt.Sprintf("/users/%s", user.ID), user_token).Put(
+		map[string]interface{}{"login": user.Login, "info": "Mine"},
+	)
+	as.Equal(200, resp.Code)
+	as.Empty(as.auditEvents(admin_token, ""))
+
+	// Admin edits somebody else's profile
+	req := as.createUpdateRequest(fmt.Sprintf("/users/%s", user.ID), admin_token)
+	req.Headers["X-Request-ID"] = "edit-42"
+	resp = req.Put(map[string]interface{}{"login": user.Login, "info": "Theirs"})
+	as.Equal(200, resp.Code)
+
+	events := as.auditEvents(admin_token, "target_id="+user.ID.String())
+	as.Equal(1, len(events))
+	as.Equal(models.AuditUserUpdate, events[0].Action)
+	as.Equal(admin.ID, events[0].ActorID.UUID)
+	as.NotEmpty(events[0].RequestID)
+	as.NotEqual("edit-42", events[0].RequestID)
+	as.Equal("edit-42", events[0].ClientRequestID)
+	as.Equal("Mine", events[0].Changes["info"].Before)
+	as.Equal("Theirs", events[0].Changes["info"].After)
+
+	// Promotion through the admin field
+	resp = as.createUpdateRequest(fmt.Sprintf("/users/%s", user.ID), admin_token).Put(
+		map[string]interface{}{"login": user.Login, "info": "Theirs", "admin": true},
+	)
+	as.Equal(200, resp.Code)
+	events = as.auditEvents(admin_token, "action="+models.AuditUserPromote)
+	as.Equal(1, len(events))
+	as.Equal(true, events[0].Changes["admin"].After)
+
+	// Reading reports
+	resp = as.createAuthRequest("/reports", moderator_token).Get()
+	as.Equal(200, resp.Code)
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s", victim.ID), moderator_token).Get()
+	as.Equal(200, resp.Code)
+	events = as.auditEvents(admin_token, "action="+models.AuditReportsRead)
+	as.Equal(2, len(events))
+	as.Equal(models.AuditTargetUser, events[0].TargetType, "Most recent first")
+	as.Equal(victim.ID, events[0].TargetID.UUID)
+	as.Equal(models.AuditTargetReport, events[1].TargetType)
+
+	// Deleting
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s", victim.ID), admin_token).Delete()
+	as.Equal(200, resp.Code)
+	events = as.auditEvents(admin_token, "action="+models.AuditUserDelete)
+	as.Equal(1, len(events))
+	as.Equal(victim.Login, events[0].Changes["login"].Before)
+
+	// Filters
+	as.Equal(5, len(as.auditEvents(admin_token, "")))
+	as.Equal(3, len(as.auditEvents(admin_token, "actor_id="+admin.ID.String())))
+	as.Equal(4, len(as.auditEvents(admin_token, "target_type="+models.AuditTargetUser)))
+	resp = as.createAuthRequest("/audit?actor_id=nope", admin_token).Get()
+	as.Equal(400, resp.Code)
+}
@@ -0,0 +1,330 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// LoginInfo holds the credentials expected by the login endpoint
+type LoginInfo struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// RefreshInfo holds the refresh token expected by the refresh and logout
+// endpoints
+type RefreshInfo struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenPair is delivered to clients upon login or token refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime (seconds)
+}
+
+// accessTokenTTL is the lifetime of access tokens (default: 15 minutes)
+func accessTokenTTL() time.Duration {
+	return models.DurationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// refreshTokenTTL is the lifetime of refresh tokens (default: 30 days)
+func refreshTokenTTL() time.Duration {
+	return models.DurationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+func newToken(u *models.User, exp time.Duration) (string, error) {
+	jti, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{}
+	claims["id"] = u.ID.String()
+	claims["ver"] = u.TokenVersion
+	claims["jti"] = jti.String()
+	claims["exp"] = time.Now().Add(exp).Unix()
+	secret, err := envy.MustGet("JWT_SECRET")
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// newTokenPair issues a new access token along with given refresh token
+func newTokenPair(u *models.User, refresh string) (*TokenPair, error) {
+	ttl := accessTokenTTL()
+	access, err := newToken(u, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(ttl.Seconds()),
+	}, nil
+}
+
+// getCredentials returns the authenticated user, as loaded from the DB by
+// the auth middleware.
+func getCredentials(c buffalo.Context) *models.User {
+	return c.Value("current_user").(*models.User)
+}
+
+// currentUser returns the authenticated user if any, or nil for anonymous
+// requests.
+func currentUser(c buffalo.Context) *models.User {
+	u, _ := c.Value("current_user").(*models.User)
+	return u
+}
+
+// rejectRevokedTokens is meant to run right after the JWT validation: it
+// turns down access tokens that were revoked before they expired.
+func rejectRevokedTokens(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return errors.WithStack(errors.New("no transaction found"))
+		}
+
+		claims := c.Value("claims").(jwt.MapClaims)
+		jti, _ := claims["jti"].(string)
+		if jti == "" {
+			return c.Error(401, errors.New("Token has no jti claim"))
+		}
+
+		revoked, err := models.IsAccessTokenRevoked(tx, jti)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if revoked {
+			return c.Error(401, errors.New("Token has been revoked"))
+		}
+		return next(c)
+	}
+}
+
+// loadCurrentUser loads the user a token was issued to, so that the rest of
+// the request relies on their current privileges rather than on the token's
+// claims. Tokens of deleted users, or issued before a privilege change, are
+// turned down.
+func loadCurrentUser(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return errors.WithStack(errors.New("no transaction found"))
+		}
+
+		claims := c.Value("claims").(jwt.MapClaims)
+		id, _ := claims["id"].(string)
+		u := &models.User{}
+		if err := tx.Find(u, uuid.FromStringOrNil(id)); err != nil {
+			return c.Error(401, errors.New("This user doesn't exist anymore"))
+		}
+
+		ver, _ := claims["ver"].(float64)
+		if int(ver) != u.TokenVersion {
+			return c.Error(401, errors.New("Privileges have changed, please log in again"))
+		}
+		if err := u.FetchRoles(tx); err != nil {
+			return errors.WithStack(err)
+		}
+
+		c.Set("current_user", u)
+		return next(c)
+	}
+}
+
+// requirePermission turns down authenticated users who lack given
+// permission. It must run after the auth middleware.
+func requirePermission(perm models.Permission) buffalo.MiddlewareFunc {
+	return func(next buffalo.Handler) buffalo.Handler {
+		return func(c buffalo.Context) error {
+			if !getCredentials(c).Can(perm) {
+				return c.Error(403, errors.New("Forbidden"))
+			}
+			return next(c)
+		}
+	}
+}
+
+// AuthLogin checks a user's credentials and delivers auth tokens.
+// @Summary Log in
+// @Description Get a short-lived Bearer token and a refresh token by
+// @Description providing a login and password
+// @Accept  json
+// @Produce  json
+// @Param credentials body actions.LoginInfo true "login and password"
+// @Success 200 {object} actions.TokenPair
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError "Invalid login or password"
+// @Failure 403 {object} FormattedError "This account has been banned"
+// @Failure 500 {object} FormattedError
+// @Router /auth/login [post]
+func AuthLogin(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	info := &LoginInfo{}
+	if err := c.Bind(info); err != nil {
+		return c.Error(400, err)
+	}
+
+	// Don't tell unknown logins apart from wrong passwords
+	u := &models.User{}
+	if err := tx.Where("login = ?", info.Login).First(u); err != nil {
+		return c.Error(401, errors.New("Invalid login or password"))
+	}
+	if !u.CheckPassword(info.Password) {
+		return c.Error(401, errors.New("Invalid login or password"))
+	}
+	if err := refuseBanned(c, tx, u); err != nil {
+		return err
+	}
+
+	rt, refresh, err := models.NewRefreshToken(u.ID, uuid.Nil, refreshTokenTTL())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := rt.Create(tx); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tokens, err := newTokenPair(u, refresh)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(tokens))
+}
+
+// AuthRefresh rotates a refresh token and delivers a new access token.
+// @Summary Refresh auth tokens
+// @Description Exchange a refresh token for a new access token and a new
+// @Description refresh token. Reusing a refresh token that was already
+// @Description rotated revokes all the tokens derived from the same login.
+// @Accept  json
+// @Produce  json
+// @Param refresh_token body actions.RefreshInfo true "refresh token"
+// @Success 200 {object} actions.TokenPair
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError "Invalid, expired or revoked refresh token"
+// @Failure 403 {object} FormattedError "This account has been banned"
+// @Failure 500 {object} FormattedError
+// @Router /auth/refresh [post]
+func AuthRefresh(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	info := &RefreshInfo{}
+	if err := c.Bind(info); err != nil {
+		return c.Error(400, err)
+	}
+
+	rt, err := models.FindRefreshToken(tx, info.RefreshToken)
+	if err != nil {
+		return c.Error(401, errors.New("Invalid refresh token"))
+	}
+
+	// This token was already rotated or revoked: someone may have stolen it,
+	// so revoke the whole family. This must outlive the current transaction,
+	// which is rolled back along with the error response.
+	reused := func() error {
+		if err := models.RevokeTokenFamily(models.DB, rt.FamilyID); err != nil {
+			return errors.WithStack(err)
+		}
+		return c.Error(401, models.ErrTokenRevoked)
+	}
+	if rt.RevokedAt.Valid {
+		return reused()
+	}
+	if rt.Expired() {
+		return c.Error(401, errors.New("Refresh token has expired"))
+	}
+
+	u := &models.User{}
+	if err := tx.Find(u, rt.UserID); err != nil {
+		return c.Error(401, errors.New("Invalid refresh token"))
+	}
+	if err := refuseBanned(c, tx, u); err != nil {
+		return err
+	}
+
+	// The token may also have been rotated by a concurrent request since it
+	// was loaded.
+	_, refresh, err := rt.Rotate(tx, refreshTokenTTL())
+	if errors.Cause(err) == models.ErrTokenRevoked {
+		return reused()
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tokens, err := newTokenPair(u, refresh)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(tokens))
+}
+
+// AuthLogout revokes the caller's access token, and optionally the refresh
+// token it was obtained with.
+// @Summary Log out
+// @Description Revoke the current access token. If a refresh token is
+// @Description provided, all the tokens of its family are revoked as well.
+// @security Bearer
+// @Accept  json
+// @Produce  json
+// @Param refresh_token body actions.RefreshInfo false "refresh token"
+// @Success 200 {object} string
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError
+// @Failure 500 {object} FormattedError
+// @Router /auth/logout [post]
+func AuthLogout(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	info := &RefreshInfo{}
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(info); err != nil {
+			return c.Error(400, err)
+		}
+	}
+
+	auth := getCredentials(c)
+	if info.RefreshToken != "" {
+		rt, err := models.FindRefreshToken(tx, info.RefreshToken)
+		if err == nil && rt.UserID == auth.ID {
+			if err := models.RevokeTokenFamily(tx, rt.FamilyID); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	claims := c.Value("claims").(jwt.MapClaims)
+	exp, _ := claims["exp"].(float64)
+	err := models.RevokeAccessToken(tx, claims["jti"].(string), time.Unix(int64(exp), 0))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON("OK"))
+}
@@ -0,0 +1,164 @@
+package actions
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+)
+
+// login logs a user in using the auth endpoint
+func (as *ActionSuite) login(login, password string) *TokenPair {
+	resp := as.JSON("/auth/login").Post(LoginInfo{Login: login, Password: password})
+	as.Equalf(200, resp.Code, resp.Body.String())
+
+	tokens := &TokenPair{}
+	err := json.Unmarshal(resp.Body.Bytes(), tokens)
+	as.NoError(err)
+	return tokens
+}
+
+// refresh exchanges a refresh token for a new token pair
+func (as *ActionSuite) refresh(refresh string) (int, *TokenPair) {
+	resp := as.JSON("/auth/refresh").Post(RefreshInfo{RefreshToken: refresh})
+	tokens := &TokenPair{}
+	if resp.Code == 200 {
+		err := json.Unmarshal(resp.Body.Bytes(), tokens)
+		as.NoError(err)
+	}
+	return resp.Code, tokens
+}
+
+func (as *ActionSuite) Test_Auth_Login() {
+	user := &models.User{
+		Login:    "toto",
+		Password: "correct horse",
+	}
+	verrs, err := user.Create(as.DB)
+	as.NoError(err)
+	as.Falsef(verrs.HasAny(), verrs.String())
+
+	req := as.JSON("/auth/login")
+
+	// Unknown user
+	resp := req.Post(map[string]string{"login": "titi", "password": "correct horse"})
+	as.Equal(401, resp.Code)
+
+	// Wrong password
+	resp = req.Post(map[string]string{"login": "toto", "password": "battery staple"})
+	as.Equal(401, resp.Code)
+
+	// Good credentials
+	tokens := as.login("toto", "correct horse")
+	as.NotEmpty(tokens.AccessToken)
+	as.NotEmpty(tokens.RefreshToken)
+	as.Equal("Bearer", tokens.TokenType)
+	as.NotZero(tokens.ExpiresIn)
+
+	// The token grants access to the user's profile
+	profile := as.loadProfileAs(user, tokens.AccessToken)
+	as.Equal(user.ID, profile.ID)
+}
+
+func (as *ActionSuite) Test_Auth_Refresh() {
+	user := &models.User{
+		Login:    "toto",
+		Password: "correct horse",
+	}
+	verrs, err := user.Create(as.DB)
+	as.NoError(err)
+	as.Falsef(verrs.HasAny(), verrs.String())
+
+	// Garbage
+	code, _ := as.refresh("not-a-token")
+	as.Equal(401, code)
+
+	first := as.login("toto", "correct horse")
+
+	// Rotate the refresh token
+	code, second := as.refresh(first.RefreshToken)
+	as.Equal(200, code)
+	as.NotEqual(first.RefreshToken, second.RefreshToken)
+	profile := as.loadProfileAs(user, second.AccessToken)
+	as.Equal(user.ID, profile.ID)
+
+	// The new refresh token can be used once
+	code, third := as.refresh(second.RefreshToken)
+	as.Equal(200, code)
+
+	// Somebody replays a rotated refresh token...
+	code, _ = as.refresh(first.RefreshToken)
+	as.Equal(401, code)
+
+	// ... which revokes the whole family, including the latest token
+	code, _ = as.refresh(third.RefreshToken)
+	as.Equal(401, code)
+
+	// Other sessions are left untouched
+	other := as.login("toto", "correct horse")
+	code, _ = as.refresh(other.RefreshToken)
+	as.Equal(200, code)
+}
+
+func (as *ActionSuite) Test_Auth_Logout() {
+	user := &models.User{
+		Login:    "toto",
+		Password: "correct horse",
+	}
+	verrs, err := user.Create(as.DB)
+	as.NoError(err)
+	as.Falsef(verrs.HasAny(), verrs.String())
+
+	tokens := as.login("toto", "correct horse")
+	url := "/auth/logout"
+
+	// Unauthorized
+	resp := as.JSON(url).Post(RefreshInfo{RefreshToken: tokens.RefreshToken})
+	as.Equal(401, resp.Code)
+
+	resp = as.createAuthRequest(url, tokens.AccessToken).Post(
+		RefreshInfo{RefreshToken: tokens.RefreshToken},
+	)
+	as.Equal(200, resp.Code)
+
+	// Both tokens are now revoked
+	resp = as.createAuthRequest("/users/"+user.ID.String(), tokens.AccessToken).Get()
+	as.Equal(401, resp.Code)
+	code, _ := as.refresh(tokens.RefreshToken)
+	as.Equal(401, code)
+}
+
+func (as *ActionSuite) Test_Auth_LiveCredentials() {
+	admin, admin_token := as.createUserAndToken(true)
+	user, user_token := as.createUserAndToken(false)
+	admin_url := "/users/" + admin.ID.String()
+
+	// Admin can see the reports
+	resp := as.createAuthRequest("/reports", admin_token).Get()
+	as.Equal(200, resp.Code)
+
+	// Admin gets demoted behind their back
+	admin.Admin = false
+	verrs, err := admin.Update(as.DB)
+	as.NoError(err)
+	as.Falsef(verrs.HasAny(), verrs.String())
+
+	// Their old token doesn't work anymore
+	resp = as.createAuthRequest("/reports", admin_token).Get()
+	as.Equal(401, resp.Code)
+
+	// A fresh token carries their new privileges
+	token, err := newToken(admin, time.Minute)
+	as.NoError(err)
+	resp = as.createAuthRequest("/reports", token).Get()
+	as.Equal(403, resp.Code)
+
+	// Other users are left untouched
+	as.loadProfileAs(admin, user_token)
+
+	// Deleted users can't act anymore
+	err = as.DB.Destroy(user)
+	as.NoError(err)
+	resp = as.createAuthRequest(admin_url, user_token).Get()
+	as.Equal(401, resp.Code)
+}
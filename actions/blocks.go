@@ -0,0 +1,82 @@
+package actions
+
+import (
+	"github.com/ArnaudCalmettes/microsocial/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// UsersBlock blocks a user
+// @Summary Block a user
+// @Description Block a user: any friendship or pending friend request
+// @Description between both users is removed, they can't request each other
+// @Description as friends anymore, and the blocked user can't see the caller.
+// @security Bearer
+// @Produce  json
+// @Param user_id path string true "The user's ID"
+// @Success 200 {object} models.Block
+// @Failure 400 {object} FormattedError "Can't block yourself"
+// @Failure 401 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Failure 500 {object} FormattedError
+// @Router /users/{user_id}/block [post]
+func UsersBlock(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	auth := getCredentials(c)
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return c.Error(404, errors.New("Not Found"))
+	}
+
+	block := &models.Block{
+		BlockerID: auth.ID,
+		BlockedID: user.ID,
+	}
+	if err := block.Create(tx); err != nil {
+		if err == models.ErrSelfBlock {
+			return c.Error(400, err)
+		}
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(block))
+}
+
+// UsersUnblock unblocks a user
+// @Summary Unblock a user
+// @Description Unblock a user
+// @security Bearer
+// @Produce  json
+// @Param user_id path string true "The user's ID"
+// @Success 200 {object} string
+// @Failure 401 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Failure 500 {object} FormattedError
+// @Router /users/{user_id}/block [delete]
+func UsersUnblock(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	auth := getCredentials(c)
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return c.Error(404, errors.New("Not Found"))
+	}
+
+	block := &models.Block{
+		BlockerID: auth.ID,
+		BlockedID: user.ID,
+	}
+	if err := block.Destroy(tx); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON("OK"))
+}
@@ -0,0 +1,75 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+)
+
+func (as *ActionSuite) Test_Blocks() {
+	alice, alice_token := as.createUserAndToken(false)
+	bob, bob_token := as.createUserAndToken(false)
+	_, admin_token := as.createUserAndToken(true)
+
+	req, err := bob.SendRequest(as.DB, alice, "")
+	as.NoError(err)
+	as.NoError(req.Accept(as.DB))
+	_, err = alice.SendRequest(as.DB, as.createRandomUser(), "")
+	as.NoError(err)
+
+	block_url := fmt.Sprintf("/users/%s/block", bob.ID)
+
+	// Unauthorized
+	resp := as.JSON(block_url).Post(nil)
+	as.Equal(401, resp.Code)
+
+	// Alice can't block herself
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s/block", alice.ID), alice_token).Post(nil)
+	as.Equal(400, resp.Code)
+
+	// Alice blocks Bob
+	resp = as.createAuthRequest(block_url, alice_token).Post(nil)
+	as.Equal(200, resp.Code)
+
+	// They aren't friends anymore
+	alice_profile := as.loadProfileAs(alice, alice_token)
+	as.Empty(alice_profile.Friends)
+	as.NotEmpty(alice_profile.OutRequests, "Unrelated requests were cancelled")
+
+	// Alice disappeared from Bob's point of view...
+	alice_url := fmt.Sprintf("/users/%s", alice.ID)
+	resp = as.createAuthRequest(alice_url, bob_token).Get()
+	as.Equal(404, resp.Code)
+
+	resp = as.createAuthRequest("/users", bob_token).Get()
+	as.Equal(200, resp.Code)
+	users := models.Users{}
+	err = json.Unmarshal(resp.Body.Bytes(), &users)
+	as.NoError(err)
+	for _, u := range users {
+		as.NotEqual(alice.ID, u.ID, "Alice is listed to Bob")
+	}
+
+	// ... so he can't request her friendship
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s/friend_request", alice.ID), bob_token).Post(nil)
+	as.Equal(404, resp.Code)
+
+	// Alice can't request his friendship either
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s/friend_request", bob.ID), alice_token).Post(nil)
+	as.Equal(409, resp.Code)
+
+	// Everybody else can still see Alice
+	resp = as.createAuthRequest(alice_url, admin_token).Get()
+	as.Equal(200, resp.Code)
+	resp = as.JSON("/users").Get()
+	as.Equal(200, resp.Code)
+	as.Contains(resp.Body.String(), alice.ID.String())
+
+	// Alice unblocks Bob
+	resp = as.createAuthRequest(block_url, alice_token).Delete()
+	as.Equal(200, resp.Code)
+
+	resp = as.createAuthRequest(alice_url, bob_token).Get()
+	as.Equal(200, resp.Code)
+}
@@ -0,0 +1,95 @@
+package actions
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// etag computes a strong entity tag out of everything a representation
+// depends on.
+func etag(parts ...interface{}) string {
+	h := sha1.New()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%v\x00", p)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// setCacheHeaders sets the validators of a representation, and tells caches
+// how to handle it. Personalized representations (e.g. a user's own
+// profile, or one loaded with extra permissions) may only be stored by the
+// client; the others may be shared. Either way, they must be revalidated
+// before being reused. modified may be zero if there is no sensible
+// modification date.
+func setCacheHeaders(c buffalo.Context, tag string, modified time.Time, personalized bool) {
+	h := c.Response().Header()
+	h.Set("ETag", tag)
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if personalized {
+		h.Set("Cache-Control", "private, no-cache")
+	} else {
+		h.Set("Cache-Control", "public, no-cache")
+	}
+	h.Add("Vary", "Authorization")
+}
+
+// notModified tells whether the client already has the current version of
+// a representation, according to the If-None-Match header or, failing that,
+// the If-Modified-Since header (RFC 7232, section 6). If-None-Match uses
+// the weak comparison function.
+func notModified(c buffalo.Context, tag string, modified time.Time) bool {
+	req := c.Request()
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return matchTag(inm, tag, true)
+	}
+
+	ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil || modified.IsZero() {
+		return false
+	}
+	// HTTP dates have a one-second precision
+	return !modified.Truncate(time.Second).After(ims)
+}
+
+// matchTag tells whether a list of entity tags, as given in an If-Match or
+// If-None-Match header, matches given tag. The weak comparison function
+// ignores the "W/" prefix of weak tags, whereas the strong one never matches
+// them.
+func matchTag(header, tag string, weak bool) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			return true
+		}
+		if strings.HasPrefix(t, "W/") {
+			if !weak {
+				continue
+			}
+			t = t[2:]
+		}
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// cached sets the cache headers of a representation, and answers with a
+// "304 Not Modified" if the client already has it. It tells whether the
+// request was handled.
+func cached(c buffalo.Context, tag string, modified time.Time, personalized bool) bool {
+	setCacheHeaders(c, tag, modified, personalized)
+	if !notModified(c, tag, modified) {
+		return false
+	}
+	c.Response().WriteHeader(http.StatusNotModified)
+	return true
+}
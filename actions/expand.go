@@ -0,0 +1,200 @@
+package actions
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// userFields lists the user columns that can be selected with the "fields"
+// parameter. Their names match their JSON keys.
+var userFields = []string{"id", "created_at", "updated_at", "login", "info", "admin"}
+
+// userRelation is an extra piece of information that can be attached to a
+// user profile with the "expand" parameter. Relations are either fetched on
+// their own, or selected to be loaded all at once by User.LoadProfile.
+type userRelation struct {
+	name    string
+	visible func(auth, user *models.User) bool
+	fetch   func(c buffalo.Context, tx *pop.Connection, auth, user *models.User) error
+	profile func(rels *models.ProfileRelations)
+}
+
+// selfOr tells whether the caller is the user themselves, or has given
+// permission.
+func selfOr(perm models.Permission) func(auth, user *models.User) bool {
+	return func(auth, user *models.User) bool {
+		return auth.ID == user.ID || auth.Can(perm)
+	}
+}
+
+// anyone can see the relation
+func anyone(auth, user *models.User) bool {
+	return true
+}
+
+// userRelations lists the relations that can be attached to a user profile,
+// along with who can see them.
+var userRelations = []userRelation{
+	{"roles", anyone, func(c buffalo.Context, tx *pop.Connection, auth, user *models.User) error {
+		return user.FetchRoles(tx)
+	}, nil},
+	// How many friends the caller has in common with this user
+	{"mutual_friend_count", anyone, func(c buffalo.Context, tx *pop.Connection, auth, user *models.User) error {
+		if auth.ID == user.ID {
+			return nil
+		}
+		return user.FetchMutualCount(tx, auth.ID)
+	}, nil},
+	{"friends", selfOr(models.PermUsersRead), nil, func(rels *models.ProfileRelations) {
+		rels.Friends = true
+	}},
+	{"pending_requests", selfOr(models.PermUsersRead), nil, func(rels *models.ProfileRelations) {
+		rels.OutRequests = true
+	}},
+	{"incoming_requests", selfOr(models.PermUsersRead), nil, func(rels *models.ProfileRelations) {
+		rels.InRequests = true
+	}},
+	{"reports", func(auth, user *models.User) bool {
+		return auth.Can(models.PermReportsRead)
+	}, nil, func(rels *models.ProfileRelations) {
+		rels.Reports = true
+	}},
+	// Users are told about their own sanctions
+	{"sanctions", selfOr(models.PermUsersSanction), func(c buffalo.Context, tx *pop.Connection, auth, user *models.User) error {
+		return user.FetchSanctions(tx)
+	}, nil},
+}
+
+// userRelationNames lists the names of the relations that can be attached
+// to a user profile.
+func userRelationNames() []string {
+	names := make([]string, len(userRelations))
+	for i, rel := range userRelations {
+		names[i] = rel.name
+	}
+	return names
+}
+
+// listParam parses a comma-separated list parameter, whose values must be
+// among the allowed ones. It returns nil if the parameter is missing.
+func listParam(c buffalo.Context, name string, allowed ...string) ([]string, error) {
+	param := c.Param(name)
+	if param == "" {
+		return nil, nil
+	}
+
+	values := []string{}
+	for _, v := range strings.Split(param, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !contains(allowed, v) {
+			return nil, c.Error(400, errors.Errorf(
+				"Invalid %s: %s (expected some of: %s)", name, v, strings.Join(allowed, ", "),
+			))
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// contains tells whether a list of strings contains given value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// expandUser attaches the requested relations to a user profile. If no
+// relation nor field was requested, every relation the caller can see is
+// attached. Explicitly requesting a relation the caller can't see is
+// forbidden.
+//
+// Friends, friend requests and reports are loaded together, in a single
+// query. Reading reports is audited.
+func expandUser(c buffalo.Context, tx *pop.Connection, user *models.User, fields, expand []string) error {
+	auth := getCredentials(c)
+	rels := models.ProfileRelations{}
+	for _, rel := range userRelations {
+		switch {
+		case fields == nil && expand == nil:
+			if !rel.visible(auth, user) {
+				continue
+			}
+		case contains(expand, rel.name):
+			if !rel.visible(auth, user) {
+				return c.Error(403, errors.Errorf("Forbidden: %s", rel.name))
+			}
+		default:
+			continue
+		}
+		if rel.profile != nil {
+			rel.profile(&rels)
+			continue
+		}
+		if err := rel.fetch(c, tx, auth, user); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if rels == (models.ProfileRelations{}) {
+		return nil
+	}
+	if err := user.LoadProfile(tx, rels); err != nil {
+		return errors.WithStack(err)
+	}
+	if !rels.Reports {
+		return nil
+	}
+	return audit(c, tx, &models.AuditEvent{
+		Action:     models.AuditReportsRead,
+		TargetType: models.AuditTargetUser,
+		TargetID:   nulls.NewUUID(user.ID),
+	})
+}
+
+// personalizedUser tells whether a user profile, with given fields and
+// relations, depends on who's asking: the user themselves, or someone
+// seeing relations that require permissions or are relative to them (such
+// as the number of mutual friends).
+func personalizedUser(auth, user *models.User, fields, expand []string) bool {
+	if auth.ID == user.ID || fields == nil && expand == nil {
+		return true
+	}
+	for _, name := range expand {
+		if name != "roles" {
+			return true
+		}
+	}
+	return false
+}
+
+// sparseUser keeps only the requested fields and relations of a user's JSON
+// representation. Relations that turned out empty are left out.
+func sparseUser(user *models.User, keys []string) (map[string]json.RawMessage, error) {
+	js, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]json.RawMessage{}
+	if err := json.Unmarshal(js, &all); err != nil {
+		return nil, err
+	}
+	sparse := make(map[string]json.RawMessage, len(keys))
+	for _, k := range keys {
+		if v, ok := all[k]; ok {
+			sparse[k] = v
+		}
+	}
+	return sparse, nil
+}
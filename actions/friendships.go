@@ -4,20 +4,38 @@ import (
 	"github.com/ArnaudCalmettes/microsocial/models"
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
 )
 
+// AutoAcceptedRequest is returned instead of a new friend request when the
+// requested user had already sent a friend request to the caller. Unlike a
+// new friend request, it comes with a "201 Created" status, as it results in
+// a new friendship.
+type AutoAcceptedRequest struct {
+	AutoAccepted bool                  `json:"auto_accepted"` // Always true
+	Request      *models.FriendRequest `json:"request"`       // The accepted request
+	Friendship   *models.Friendship    `json:"friendship"`    // The resulting friendship
+}
+
 // FriendRequestsCreate places a new friend request.
+// If the requested user has already sent a pending request to the caller,
+// this request is accepted instead.
 // @Summary Send a friend request to a user
-// @Description Send a friend request to a user
+// @Description Send a friend request to a user. If this user already sent
+// @Description a pending friend request to the caller, it is automatically
+// @Description accepted and an actions.AutoAcceptedRequest is returned
+// @Description instead, with a 201 status since a friendship was created.
 // @security Bearer
 // @Accept  json
 // @Produce  json
 // @Param user_id path string true "The user's ID"
 // @Param message body models.LightFriendRequest true "message associated to the friend request"
-// @Success 200 {object} models.FriendRequest
+// @Success 200 {object} models.FriendRequest "The new friend request"
+// @Success 201 {object} actions.AutoAcceptedRequest "The crossing request was accepted"
 // @Failure 400 {object} FormattedError
 // @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError "The caller was reported too often lately"
 // @Failure 404 {object} FormattedError
 // @Failure 409 {object} FormattedError "You can't request yourself as a friend"
 // @Router /users/{user_id}/friend_request [post]
@@ -28,9 +46,9 @@ func FriendRequestsCreate(c buffalo.Context) error {
 	}
 
 	auth := getCredentials(c)
-	user := &models.User{}
-	if err := tx.Find(user, c.Param("user_id")); err != nil {
-		return c.Error(404, errors.New("Not Found"))
+	user, err := findVisibleUser(c, tx)
+	if err != nil {
+		return err
 	}
 
 	light_req := &models.LightFriendRequest{}
@@ -38,11 +56,23 @@ func FriendRequestsCreate(c buffalo.Context) error {
 		return c.Error(400, err)
 	}
 
+	// Crossing requests: accept the existing one
+	reverse, err := models.FindPendingRequest(tx, user.ID, auth.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if reverse != nil {
+		return acceptCrossingRequest(c, tx, reverse)
+	}
+
 	req := models.FriendRequestFromLight(light_req)
 	req.FromID = auth.ID
 	req.ToID = user.ID
 
 	verrs, err := req.Create(tx)
+	if len(verrs.Get(models.FriendRequestRestrictedKey)) > 0 {
+		return c.Error(403, verrs)
+	}
 	if verrs.HasAny() {
 		return c.Error(409, verrs)
 	}
@@ -53,6 +83,27 @@ func FriendRequestsCreate(c buffalo.Context) error {
 	return c.Render(200, r.JSON(req))
 }
 
+// acceptCrossingRequest accepts a pending friend request on behalf of its
+// recipient, who just tried to send one the other way round.
+func acceptCrossingRequest(c buffalo.Context, tx *pop.Connection, req *models.FriendRequest) error {
+	if err := req.Accept(tx); err != nil {
+		// The request may have been resolved concurrently
+		if err == models.ErrRequestNotPending {
+			return c.Error(409, err)
+		}
+		return errors.WithStack(err)
+	}
+	fs, err := models.FindFriendship(tx, req.ToID, req.FromID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(201, r.JSON(&AutoAcceptedRequest{
+		AutoAccepted: true,
+		Request:      req,
+		Friendship:   fs,
+	}))
+}
+
 // FriendshipDestroy Unfriends a friend
 // @Summary Unfriend another user
 // @Description Unfriend another user
@@ -91,6 +142,208 @@ func FriendshipsDestroy(c buffalo.Context) error {
 	return c.Render(200, r.JSON("OK"))
 }
 
+// FriendshipsList lists a user's friends
+// @Summary List a user's friends
+// @Description List a user's friends, most recent friendships first. Only
+// @Description users themselves (or users with the "users:read" permission)
+// @Description can see their friends. Cursors ("after" or "before", and
+// @Description "limit") can be used instead of page numbers.
+// @security Bearer
+// @Produce  json
+// @Param user_id path string true "The user's ID"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Param after query string false "Cursor: list the friends after this one"
+// @Param before query string false "Cursor: list the friends before this one"
+// @Param limit query int false "Items per page, when using cursors"
+// @Success 200 {object} models.Users
+// @Header 200  {object} X-Pagination "pagination information"
+// @Header 200  {string} Link "previous and next pages, when using cursors"
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Router /users/{user_id}/friends [get]
+func FriendshipsList(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user, err := findVisibleUser(c, tx)
+	if err != nil {
+		return err
+	}
+	auth := getCredentials(c)
+	if auth.ID != user.ID && !auth.Can(models.PermUsersRead) {
+		return c.Error(403, errors.New("Forbidden"))
+	}
+
+	page, err := cursorPage(c)
+	if err != nil {
+		return err
+	}
+	if page != nil {
+		prev, next, err := user.FetchFriendsPage(tx, page)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		setPageLinks(c, page, prev, next)
+		return c.Render(200, r.JSON(user.Friends))
+	}
+
+	users := &models.Users{}
+	q := models.FriendsQuery(tx.PaginateFromParams(c.Params()), user.ID)
+	if err := q.All(users); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Add X-Pagination header
+	c.Set("pagination", q.Paginator)
+	return c.Render(200, r.JSON(users))
+}
+
+// FriendshipsMutual lists the friends the caller has in common with a user.
+// @Summary List mutual friends
+// @Description List the friends the caller and another user have in common
+// @security Bearer
+// @Produce  json
+// @Param user_id path string true "The user's ID"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} models.Users
+// @Header 200  {object} X-Pagination "pagination information"
+// @Failure 401 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Failure 500 {object} FormattedError
+// @Router /users/{user_id}/mutual_friends [get]
+func FriendshipsMutual(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user, err := findVisibleUser(c, tx)
+	if err != nil {
+		return err
+	}
+
+	users := &models.Users{}
+	q := models.MutualFriendsQuery(tx.PaginateFromParams(c.Params()), getCredentials(c).ID, user.ID)
+	if err := q.All(users); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Add X-Pagination header
+	c.Set("pagination", q.Paginator)
+	return c.Render(200, r.JSON(users))
+}
+
+// FriendSuggestionsList suggests new friends to the caller.
+// @Summary People you may know
+// @Description List the friends of the caller's friends, ranked by the number
+// @Description of mutual friends, with a few of these mutual friends.
+// @Description Friends, users with a pending friend request to or from the
+// @Description caller and blocked users are never suggested.
+// @security Bearer
+// @Produce  json
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} models.Suggestions
+// @Header 200  {object} X-Pagination "pagination information"
+// @Failure 401 {object} FormattedError
+// @Failure 500 {object} FormattedError
+// @Router /me/suggestions [get]
+func FriendSuggestionsList(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	q := tx.PaginateFromParams(c.Params())
+	suggestions, err := getCredentials(c).FetchSuggestions(q)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Add X-Pagination header
+	c.Set("pagination", q.Paginator)
+	return c.Render(200, r.JSON(suggestions))
+}
+
+// friendPathMaxDepth is the maximum number of friendships in a path
+// (default: 6)
+func friendPathMaxDepth() int {
+	return models.IntFromEnv("FRIEND_PATH_MAX_DEPTH", 6)
+}
+
+// FriendshipsPath finds the shortest chain of friends between two users.
+// @Summary Show how two users are connected
+// @Description Show the shortest chain of friends from a user to another,
+// @Description both included. Users on either side of a block with the caller
+// @Description are never part of the chain. The chain can't be longer than
+// @Description FRIEND_PATH_MAX_DEPTH friendships (6 by default).
+// @Description Only the caller's own connections can be looked up, unless
+// @Description they have the users:read permission.
+// @security Bearer
+// @Produce  json
+// @Param user_id path string true "ID of the first user"
+// @Param to query string true "ID of the last user"
+// @Success 200 {object} models.Users
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Failure 404 {object} FormattedError "No path was found"
+// @Failure 500 {object} FormattedError
+// @Router /users/{user_id}/path [get]
+func FriendshipsPath(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user, err := findVisibleUser(c, tx)
+	if err != nil {
+		return err
+	}
+
+	auth := getCredentials(c)
+	if auth.ID != user.ID && !auth.Can(models.PermUsersRead) {
+		return c.Error(403, errors.New("Forbidden"))
+	}
+
+	toID, err := uuid.FromString(c.Param("to"))
+	if err != nil {
+		return c.Error(400, errors.New("Parameter 'to' must be a user ID"))
+	}
+
+	excluded, err := models.BlockedWith(tx, auth.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, id := range excluded {
+		if id == toID {
+			return c.Error(404, errors.New("Not Found"))
+		}
+	}
+	exists, err := tx.Where("id = ?", toID).Exists("users")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !exists {
+		return c.Error(404, errors.New("Not Found"))
+	}
+
+	path, err := models.FindFriendPath(tx, user.ID, toID, friendPathMaxDepth(), excluded)
+	if err == models.ErrNoPath || err == models.ErrPathTooBroad {
+		return c.Error(404, err)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(200, r.JSON(path))
+}
+
 // FriendRequestsAccept accepts a friend request.
 // @Summary Accept a friend request
 // @Description Accept a friend request
@@ -101,6 +354,7 @@ func FriendshipsDestroy(c buffalo.Context) error {
 // @Failure 401 {object} FormattedError
 // @Failure 403 {object} FormattedError "This request isn't yours to accept"
 // @Failure 404 {object} FormattedError
+// @Failure 409 {object} FormattedError "This friend request isn't pending anymore"
 // @Failure 500 {object} FormattedError
 // @Router /friend_requests/{request_id}/accept [get]
 func FriendRequestsAccept(c buffalo.Context) error {
@@ -119,6 +373,9 @@ func FriendRequestsAccept(c buffalo.Context) error {
 	}
 
 	if err := req.Accept(tx); err != nil {
+		if err == models.ErrRequestNotPending {
+			return c.Error(409, err)
+		}
 		return errors.WithStack(err)
 	}
 
@@ -135,6 +392,7 @@ func FriendRequestsAccept(c buffalo.Context) error {
 // @Failure 401 {object} FormattedError
 // @Failure 403 {object} FormattedError "This request isn't yours to decline"
 // @Failure 404 {object} FormattedError
+// @Failure 409 {object} FormattedError "This friend request isn't pending anymore"
 // @Failure 500 {object} FormattedError
 // @Router /friend_requests/{request_id}/decline [get]
 func FriendRequestsDecline(c buffalo.Context) error {
@@ -152,8 +410,90 @@ func FriendRequestsDecline(c buffalo.Context) error {
 	}
 
 	if err := req.Decline(tx); err != nil {
+		if err == models.ErrRequestNotPending {
+			return c.Error(409, err)
+		}
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(req))
+}
+
+// FriendRequestsCancel cancels a friend request.
+// @Summary Cancel a friend request
+// @Description Cancel a pending friend request (only its sender can)
+// @security Bearer
+// @Produce  json
+// @Param request_id path string true "The friend request ID"
+// @Success 200 {object} models.FriendRequest
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError "This request isn't yours to cancel"
+// @Failure 404 {object} FormattedError
+// @Failure 409 {object} FormattedError "This friend request isn't pending anymore"
+// @Failure 500 {object} FormattedError
+// @Router /friend_requests/{request_id} [delete]
+func FriendRequestsCancel(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	req := &models.FriendRequest{}
+	if err := tx.Find(req, c.Param("request_id")); err != nil {
+		return c.Error(404, err)
+	}
+	auth := getCredentials(c)
+	if req.FromID != auth.ID {
+		return c.Error(403, errors.New("This request isn't yours to cancel."))
+	}
+
+	if err := req.Cancel(tx); err != nil {
+		if err == models.ErrRequestNotPending {
+			return c.Error(409, err)
+		}
 		return errors.WithStack(err)
 	}
 
 	return c.Render(200, r.JSON(req))
 }
+
+// FriendRequestsList lists the caller's pending friend requests.
+// @Summary List pending friend requests
+// @Description List the caller's pending incoming (default) or outgoing friend requests
+// @security Bearer
+// @Produce  json
+// @Param direction query string false "Incoming or outgoing requests" Enums(in, out)
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} models.FriendRequests
+// @Header 200  {object} X-Pagination "pagination information"
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError
+// @Failure 500 {object} FormattedError
+// @Router /friend_requests/ [get]
+func FriendRequestsList(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	auth := getCredentials(c)
+	q := tx.PaginateFromParams(c.Params())
+
+	switch c.Param("direction") {
+	case "", "in":
+		q = q.Eager("From").Where("to_id = ?", auth.ID)
+	case "out":
+		q = q.Eager("To").Where("from_id = ?", auth.ID)
+	default:
+		return c.Error(400, errors.New("direction must be either 'in' or 'out'"))
+	}
+
+	reqs := &models.FriendRequests{}
+	q = q.Where("status = ?", models.RequestPending).Order("created_at desc")
+	if err := q.All(reqs); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("pagination", q.Paginator)
+	return c.Render(200, r.JSON(reqs))
+}
@@ -87,14 +87,6 @@ func (as *ActionSuite) Test_Friendship() {
 
 	///////////////////////////////////////////////////////////////////////////
 
-	bob_fr_url := fmt.Sprintf("/users/%s/friend_request", bob.ID)
-
-	// Check that Alice can't request friendship to Bob since there's already a
-	// request from Bob to Alice. We're not Tinder: simply send a "clash" error
-	// to avoid breaking the database's consistency.
-	resp = as.createAuthRequest(bob_fr_url, alice_token).Post(payload)
-	as.Equal(409, resp.Code)
-
 	// Check that only Alice can accept or decline this friend request
 	accept_url := fmt.Sprintf("/friend_requests/%s/accept", bob_alice_req.ID)
 	decline_url := fmt.Sprintf("/friend_requests/%s/decline", bob_alice_req.ID)
@@ -119,7 +111,14 @@ func (as *ActionSuite) Test_Friendship() {
 	// so she tries to "accept it back"
 	resp = as.createAuthRequest(accept_url, alice_token).Get()
 
-	as.Equal(404, resp.Code) // ... But she can't, because the request is lost.
+	as.Equal(409, resp.Code) // ... But she can't, because it's been declined.
+
+	// The request is still on record, though
+	declined := &models.FriendRequest{}
+	err = as.DB.Find(declined, bob_alice_req.ID)
+	as.NoError(err)
+	as.Equal(models.RequestDeclined, declined.Status)
+	as.True(declined.RespondedAt.Valid)
 
 	// Check that the request disappeared from Alice and Bob's profiles
 	alice_profile = as.loadProfileAs(alice, admin_token)
@@ -132,6 +131,8 @@ func (as *ActionSuite) Test_Friendship() {
 	as.Equal(200, resp.Code)
 	err = json.Unmarshal(resp.Body.Bytes(), bob_alice_req)
 	as.NoError(err)
+	as.Equal(models.RequestPending, bob_alice_req.Status)
+	as.False(bob_alice_req.RespondedAt.Valid)
 
 	accept_url = fmt.Sprintf("/friend_requests/%s/accept", bob_alice_req.ID)
 	decline_url = fmt.Sprintf("/friend_requests/%s/decline", bob_alice_req.ID)
@@ -140,9 +141,15 @@ func (as *ActionSuite) Test_Friendship() {
 	resp = as.createAuthRequest(accept_url, alice_token).Get()
 	as.Equal(200, resp.Code)
 
+	accepted := &models.FriendRequest{}
+	err = json.Unmarshal(resp.Body.Bytes(), accepted)
+	as.NoError(err)
+	as.Equal(models.RequestAccepted, accepted.Status)
+	as.True(accepted.RespondedAt.Valid)
+
 	// Now she can't decline it anymore
 	resp = as.createAuthRequest(decline_url, alice_token).Get()
-	as.Equal(404, resp.Code)
+	as.Equal(409, resp.Code)
 
 	///////////////////////////////////////////////////////////////////////////
 	// Check friendship visibility
@@ -182,3 +189,320 @@ func (as *ActionSuite) Test_Friendship() {
 	// If we reach here, then we can celebrate that Alice and Bob's very short
 	// relationship made it through to its weird conclusion. Yay! \o/
 }
+
+func (as *ActionSuite) Test_FriendRequests_Cancel() {
+	alice, alice_token := as.createUserAndToken(false)
+	bob, bob_token := as.createUserAndToken(false)
+
+	req, err := bob.SendRequest(as.DB, alice, "Let's be friends!")
+	as.NoError(err)
+
+	url := fmt.Sprintf("/friend_requests/%s", req.ID)
+
+	// Unauthorized
+	resp := as.JSON(url).Delete()
+	as.Equal(401, resp.Code)
+
+	// Only the sender can cancel the request
+	resp = as.createAuthRequest(url, alice_token).Delete()
+	as.Equal(403, resp.Code)
+
+	resp = as.createAuthRequest(url, bob_token).Delete()
+	as.Equal(200, resp.Code)
+
+	cancelled := &models.FriendRequest{}
+	err = json.Unmarshal(resp.Body.Bytes(), cancelled)
+	as.NoError(err)
+	as.Equal(models.RequestCancelled, cancelled.Status)
+
+	// Once cancelled, it can't be cancelled or accepted anymore
+	resp = as.createAuthRequest(url, bob_token).Delete()
+	as.Equal(409, resp.Code)
+	resp = as.createAuthRequest(url+"/accept", alice_token).Get()
+	as.Equal(409, resp.Code)
+
+	alice_profile := as.loadProfileAs(alice, alice_token)
+	as.Empty(alice_profile.InRequests)
+
+	// Unknown request
+	resp = as.createAuthRequest("/friend_requests/non-existent", bob_token).Delete()
+	as.Equal(404, resp.Code)
+}
+
+func (as *ActionSuite) Test_FriendRequests_List() {
+	alice, alice_token := as.createUserAndToken(false)
+	bob, bob_token := as.createUserAndToken(false)
+	others := as.createRandomUsers(3)
+
+	_, err := bob.SendRequest(as.DB, alice, "")
+	as.NoError(err)
+	for i := range others {
+		_, err := others[i].SendRequest(as.DB, alice, "")
+		as.NoError(err)
+	}
+	declined, err := alice.SendRequest(as.DB, &others[0], "")
+	as.NoError(err)
+	as.NoError(declined.Decline(as.DB))
+
+	// Unauthorized
+	resp := as.JSON("/friend_requests").Get()
+	as.Equal(401, resp.Code)
+
+	// Invalid direction
+	resp = as.createAuthRequest("/friend_requests?direction=up", alice_token).Get()
+	as.Equal(400, resp.Code)
+
+	// Incoming requests (default)
+	resp = as.createAuthRequest("/friend_requests", alice_token).Get()
+	as.Equal(200, resp.Code)
+	reqs := models.FriendRequests{}
+	err = json.Unmarshal(resp.Body.Bytes(), &reqs)
+	as.NoError(err)
+	as.Equal(4, len(reqs))
+	for _, req := range reqs {
+		as.NotNil(req.From)
+		as.Nil(req.To)
+	}
+
+	// Paginated
+	resp = as.createAuthRequest("/friend_requests?direction=in&per_page=3", alice_token).Get()
+	as.Equal(200, resp.Code)
+	as.NotEmpty(resp.Header().Get("X-Pagination"))
+	reqs = models.FriendRequests{}
+	err = json.Unmarshal(resp.Body.Bytes(), &reqs)
+	as.NoError(err)
+	as.Equal(3, len(reqs))
+
+	// Alice has no pending outgoing request
+	resp = as.createAuthRequest("/friend_requests?direction=out", alice_token).Get()
+	as.Equal(200, resp.Code)
+	reqs = models.FriendRequests{}
+	err = json.Unmarshal(resp.Body.Bytes(), &reqs)
+	as.NoError(err)
+	as.Empty(reqs)
+
+	// Bob has one
+	resp = as.createAuthRequest("/friend_requests?direction=out", bob_token).Get()
+	as.Equal(200, resp.Code)
+	reqs = models.FriendRequests{}
+	err = json.Unmarshal(resp.Body.Bytes(), &reqs)
+	as.NoError(err)
+	as.Equal(1, len(reqs))
+	as.Equal(alice.ID, reqs[0].To.ID)
+	as.Nil(reqs[0].From)
+}
+
+func (as *ActionSuite) Test_FriendRequests_AutoAccept() {
+	alice, alice_token := as.createUserAndToken(false)
+	bob, bob_token := as.createUserAndToken(false)
+	payload := &map[string]string{"message": "Let's be friends!"}
+
+	// Bob requests friendship to Alice
+	resp := as.createAuthRequest(
+		fmt.Sprintf("/users/%s/friend_request", alice.ID), bob_token,
+	).Post(payload)
+	as.Equal(200, resp.Code)
+
+	bob_alice_req := &models.FriendRequest{}
+	err := json.Unmarshal(resp.Body.Bytes(), bob_alice_req)
+	as.NoError(err)
+
+	// Alice, unaware of it, requests friendship to Bob: since they both want
+	// the same thing, Bob's request gets accepted.
+	resp = as.createAuthRequest(
+		fmt.Sprintf("/users/%s/friend_request", bob.ID), alice_token,
+	).Post(payload)
+	as.Equal(201, resp.Code)
+
+	result := &AutoAcceptedRequest{}
+	err = json.Unmarshal(resp.Body.Bytes(), result)
+	as.NoError(err)
+	as.True(result.AutoAccepted)
+	as.Equal(bob_alice_req.ID, result.Request.ID)
+	as.Equal(models.RequestAccepted, result.Request.Status)
+	as.Equal(alice.ID, result.Friendship.UserID)
+	as.Equal(bob.ID, result.Friendship.FriendID)
+
+	// No new request was created
+	count, err := as.DB.Count("friend_requests")
+	as.NoError(err)
+	as.Equal(1, count)
+
+	alice_profile := as.loadProfileAs(alice, alice_token)
+	as.Empty(alice_profile.InRequests)
+	as.NotEmpty(alice_profile.Friends)
+	as.Equal(bob.ID, alice_profile.Friends[0].ID)
+
+	bob_profile := as.loadProfileAs(bob, bob_token)
+	as.Empty(bob_profile.OutRequests)
+	as.NotEmpty(bob_profile.Friends)
+	as.Equal(alice.ID, bob_profile.Friends[0].ID)
+}
+
+func (as *ActionSuite) makeFriends(user, other *models.User) {
+	req, err := user.SendRequest(as.DB, other, "")
+	as.NoError(err)
+	as.NoError(req.Accept(as.DB))
+}
+
+func (as *ActionSuite) Test_Friendships_List() {
+	alice, alice_token := as.createUserAndToken(false)
+	_, bob_token := as.createUserAndToken(false)
+	_, support_token := as.createUserWithRole(models.RoleSupport)
+	friends := as.createRandomUsers(3)
+	for i := range friends {
+		as.makeFriends(alice, &friends[i])
+	}
+
+	url := fmt.Sprintf("/users/%s/friends", alice.ID)
+
+	// Friends lists are private
+	resp := as.createAuthRequest(url, bob_token).Get()
+	as.Equal(403, resp.Code)
+
+	for _, token := range []string{alice_token, support_token} {
+		resp = as.createAuthRequest(url, token).Get()
+		as.Equal(200, resp.Code)
+		as.NotEmpty(resp.Header().Get("X-Pagination"))
+		users := models.Users{}
+		as.NoError(json.Unmarshal(resp.Body.Bytes(), &users))
+		as.Equal(3, len(users))
+		as.Equal(friends[2].ID, users[0].ID, "Most recent friends first")
+	}
+
+	// With cursors
+	resp = as.createAuthRequest(url+"?limit=2", alice_token).Get()
+	as.Equal(200, resp.Code)
+	users := models.Users{}
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &users))
+	as.Equal(2, len(users))
+	as.Equal(friends[2].ID, users[0].ID)
+
+	next := pageLink(resp.Header().Get("Link"), "next")
+	as.NotEmpty(next)
+	resp = as.createAuthRequest(next, alice_token).Get()
+	as.Equal(200, resp.Code)
+	users = models.Users{}
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &users))
+	as.Equal(1, len(users))
+	as.Equal(friends[0].ID, users[0].ID)
+	as.Empty(pageLink(resp.Header().Get("Link"), "next"))
+	as.NotEmpty(pageLink(resp.Header().Get("Link"), "prev"))
+}
+
+func (as *ActionSuite) Test_Friendships_Mutual() {
+	alice, alice_token := as.createUserAndToken(false)
+	bob, bob_token := as.createUserAndToken(false)
+	carol := as.createRandomUser()
+	dave := as.createRandomUser()
+
+	as.makeFriends(alice, carol)
+	as.makeFriends(alice, dave)
+	as.makeFriends(bob, carol)
+
+	mutual_url := fmt.Sprintf("/users/%s/mutual_friends", bob.ID)
+
+	// Unauthorized
+	resp := as.JSON(mutual_url).Get()
+	as.Equal(401, resp.Code)
+
+	resp = as.createAuthRequest(mutual_url, alice_token).Get()
+	as.Equal(200, resp.Code)
+	as.NotEmpty(resp.Header().Get("X-Pagination"))
+
+	users := models.Users{}
+	err := json.Unmarshal(resp.Body.Bytes(), &users)
+	as.NoError(err)
+	as.Equal(1, len(users))
+	as.Equal(carol.ID, users[0].ID)
+
+	// The mutual friend count shows on other people's profiles only
+	bob_profile := as.loadProfileAs(bob, alice_token)
+	as.NotNil(bob_profile.MutualCount)
+	as.Equal(1, *bob_profile.MutualCount)
+
+	alice_profile := as.loadProfileAs(alice, alice_token)
+	as.Nil(alice_profile.MutualCount)
+
+	// Blocked users can't look at their blocker's friends
+	block := &models.Block{BlockerID: alice.ID, BlockedID: bob.ID}
+	as.NoError(block.Create(as.DB))
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s/mutual_friends", alice.ID), bob_token).Get()
+	as.Equal(404, resp.Code)
+}
+
+func (as *ActionSuite) Test_FriendSuggestions_List() {
+	alice, alice_token := as.createUserAndToken(false)
+	bob := as.createRandomUser()
+	carol := as.createRandomUser()
+	dave := as.createRandomUser()
+
+	as.makeFriends(alice, bob)
+	as.makeFriends(bob, carol)
+	as.makeFriends(bob, dave)
+	_, err := alice.SendRequest(as.DB, dave, "")
+	as.NoError(err)
+
+	// Unauthorized
+	resp := as.JSON("/me/suggestions").Get()
+	as.Equal(401, resp.Code)
+
+	resp = as.createAuthRequest("/me/suggestions", alice_token).Get()
+	as.Equal(200, resp.Code)
+	as.NotEmpty(resp.Header().Get("X-Pagination"))
+
+	suggestions := models.Suggestions{}
+	err = json.Unmarshal(resp.Body.Bytes(), &suggestions)
+	as.NoError(err)
+	as.Equal(1, len(suggestions), "Dave already has a pending request")
+	as.Equal(carol.ID, suggestions[0].User.ID)
+	as.Equal(1, suggestions[0].MutualCount)
+	as.Equal(bob.ID, suggestions[0].MutualFriends[0].ID)
+}
+
+func (as *ActionSuite) Test_Friendships_Path() {
+	alice, alice_token := as.createUserAndToken(false)
+	bob, bob_token := as.createUserAndToken(false)
+	carol := as.createRandomUser()
+	dave := as.createRandomUser()
+	_, admin_token := as.createUserAndToken(true)
+
+	as.makeFriends(alice, bob)
+	as.makeFriends(bob, carol)
+	as.makeFriends(carol, dave)
+
+	path_url := fmt.Sprintf("/users/%s/path?to=%s", alice.ID, dave.ID)
+
+	// Unauthorized
+	resp := as.JSON(path_url).Get()
+	as.Equal(401, resp.Code)
+
+	// Bob can't look up Alice's connections...
+	resp = as.createAuthRequest(path_url, bob_token).Get()
+	as.Equal(403, resp.Code)
+
+	// ... but she and admins can
+	for _, token := range []string{alice_token, admin_token} {
+		resp = as.createAuthRequest(path_url, token).Get()
+		as.Equal(200, resp.Code)
+
+		path := models.Users{}
+		err := json.Unmarshal(resp.Body.Bytes(), &path)
+		as.NoError(err)
+		as.Equal(4, len(path))
+		as.Equal(alice.ID, path[0].ID)
+		as.Equal(bob.ID, path[1].ID)
+		as.Equal(carol.ID, path[2].ID)
+		as.Equal(dave.ID, path[3].ID)
+	}
+
+	// Invalid destination
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s/path?to=nope", alice.ID), alice_token).Get()
+	as.Equal(400, resp.Code)
+
+	// Paths don't go through blocked users
+	block := &models.Block{BlockerID: carol.ID, BlockedID: alice.ID}
+	as.NoError(block.Create(as.DB))
+	resp = as.createAuthRequest(path_url, alice_token).Get()
+	as.Equal(404, resp.Code)
+}
@@ -0,0 +1,132 @@
+package actions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// sortOrder parses the "sort" parameter: a comma-separated list of fields,
+// each optionally prefixed with "-" for a descending order, and returns the
+// matching ORDER BY clause. Only the given fields of given table can be used,
+// and the table's "id" is always appended as a last resort, so that pages
+// stay stable between requests. An empty clause is returned when the
+// parameter is missing.
+func sortOrder(c buffalo.Context, table string, fields ...string) (string, error) {
+	param := c.Param("sort")
+	if param == "" {
+		return "", nil
+	}
+
+	allowed := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		allowed[f] = true
+	}
+
+	clauses := []string{}
+	for _, f := range strings.Split(param, ",") {
+		dir := "ASC"
+		if strings.HasPrefix(f, "-") {
+			f, dir = f[1:], "DESC"
+		}
+		if !allowed[f] {
+			return "", c.Error(400, errors.Errorf(
+				"Invalid sort field: %s (expected one of: %s)", f, strings.Join(fields, ", "),
+			))
+		}
+		clauses = append(clauses, table+"."+f+" "+dir)
+	}
+	return strings.Join(append(clauses, table+".id"), ", "), nil
+}
+
+// filterCreated narrows a query down to the rows of given table created
+// within the range given by the "created_after" and "created_before"
+// parameters (RFC 3339 dates, both optional).
+func filterCreated(c buffalo.Context, q *pop.Query, table string) (*pop.Query, error) {
+	for param, op := range map[string]string{"created_after": ">", "created_before": "<"} {
+		value := c.Param(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, c.Error(400, errors.Errorf("Invalid %s: %s", param, value))
+		}
+		q = q.Where(table+".created_at "+op+" ?", t)
+	}
+	return q, nil
+}
+
+// maxCursorLimit is the maximum number of rows per page with cursors
+const maxCursorLimit = 100
+
+// cursorPage parses the keyset pagination parameters: "after" or "before"
+// (a cursor) and "limit" (20 by default). It returns nil if none of them is
+// given, in which case the classic "page" and "per_page" parameters apply.
+func cursorPage(c buffalo.Context) (*models.Page, error) {
+	after, before, limit := c.Param("after"), c.Param("before"), c.Param("limit")
+	if after == "" && before == "" && limit == "" {
+		return nil, nil
+	}
+	switch {
+	case c.Param("page") != "":
+		return nil, c.Error(400, errors.New("Cursors and page numbers can't be mixed"))
+	case after != "" && before != "":
+		return nil, c.Error(400, errors.New("Only one of after and before can be given"))
+	}
+
+	p := &models.Page{Limit: 20}
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 1 || l > maxCursorLimit {
+			return nil, c.Error(400, errors.Errorf(
+				"Invalid limit: %s (expected 1 to %d)", limit, maxCursorLimit,
+			))
+		}
+		p.Limit = l
+	}
+
+	var err error
+	if after != "" {
+		p.After, err = models.ParseCursor(after)
+	}
+	if before != "" {
+		p.Before, err = models.ParseCursor(before)
+	}
+	if err != nil {
+		return nil, c.Error(400, err)
+	}
+	return p, nil
+}
+
+// setPageLinks sets the Link header pointing to the previous and next pages
+// of a list paginated with cursors.
+func setPageLinks(c buffalo.Context, p *models.Page, prev, next *models.Cursor) {
+	links := []string{}
+	for _, link := range []struct {
+		rel    string
+		param  string
+		cursor *models.Cursor
+	}{{"prev", "before", prev}, {"next", "after", next}} {
+		if link.cursor == nil {
+			continue
+		}
+		u := *c.Request().URL
+		q := u.Query()
+		q.Del("after")
+		q.Del("before")
+		q.Set(link.param, link.cursor.String())
+		q.Set("limit", strconv.Itoa(p.Limit))
+		u.RawQuery = q.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), link.rel))
+	}
+	if len(links) > 0 {
+		c.Response().Header().Set("Link", strings.Join(links, ", "))
+	}
+}
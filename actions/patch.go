@@ -0,0 +1,94 @@
+package actions
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/pkg/errors"
+)
+
+// userWritableFields lists the user fields that can be modified with a merge
+// patch, along with the permission they require, if any.
+var userWritableFields = map[string]models.Permission{
+	"login": "",
+	"info":  "",
+	"admin": models.PermUsersPromote,
+}
+
+// readMergePatch reads the JSON merge patch (RFC 7396) in the request's
+// body. As the patch applies to a user, it must be a JSON object.
+//
+// Note that requests are always considered to be JSON (see App), so both the
+// "application/merge-patch+json" and "application/json" media types work.
+func readMergePatch(c buffalo.Context) (map[string]json.RawMessage, error) {
+	patch := map[string]json.RawMessage{}
+	if err := json.NewDecoder(c.Request().Body).Decode(&patch); err != nil || patch == nil {
+		return nil, c.Error(400, errors.New("Invalid merge patch: expected a JSON object"))
+	}
+	return patch, nil
+}
+
+// patchUser applies a merge patch to a user. Every field of the patch is
+// checked before anything is applied, and all the problems are reported at
+// once: unknown fields, fields that can't be modified (by the caller), and
+// values of the wrong type. A null info is cleared, but the login and admin
+// flag can't be removed.
+func patchUser(c buffalo.Context, user *models.User, patch map[string]json.RawMessage) error {
+	auth := getCredentials(c)
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	problems := []string{}
+	for _, k := range keys {
+		perm, writable := userWritableFields[k]
+		switch {
+		case !writable && (contains(userFields, k) || contains(userRelationNames(), k)):
+			problems = append(problems, k+" is read-only")
+		case !writable:
+			problems = append(problems, k+" is unknown")
+		case perm != "" && !auth.Can(perm):
+			problems = append(problems, k+" requires the "+string(perm)+" permission")
+		}
+	}
+	if len(problems) > 0 {
+		return c.Error(400, errors.Errorf("Invalid merge patch: %s", strings.Join(problems, ", ")))
+	}
+
+	patched := *user
+	for _, k := range keys {
+		v := patch[k]
+		if string(v) == "null" {
+			if k == "info" {
+				patched.Info = ""
+			} else {
+				problems = append(problems, k+" can't be removed")
+			}
+			continue
+		}
+
+		var err error
+		switch k {
+		case "login":
+			err = json.Unmarshal(v, &patched.Login)
+		case "info":
+			err = json.Unmarshal(v, &patched.Info)
+		case "admin":
+			err = json.Unmarshal(v, &patched.Admin)
+		}
+		if err != nil {
+			problems = append(problems, k+" has the wrong type")
+		}
+	}
+	if len(problems) > 0 {
+		return c.Error(400, errors.Errorf("Invalid merge patch: %s", strings.Join(problems, ", ")))
+	}
+
+	*user = patched
+	return nil
+}
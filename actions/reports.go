@@ -3,23 +3,28 @@ package actions
 import (
 	"github.com/ArnaudCalmettes/microsocial/models"
 	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
 )
 
 // ReportsCreate reports given user
 // @Summary Report a user to the moderators
-// @Description Report a user to the moderators
+// @Description Report a user to the moderators. Users can only have one
+// @Description open report about somebody, and can't file more than
+// @Description REPORTS_DAILY_QUOTA reports (10 by default) within 24 hours.
 // @security Bearer
 // @Accept  json
 // @Produce  json
 // @Param user_id path string true "The user's ID"
-// @Param userinfo body models.LightReport true "Mandatory report information"
+// @Param userinfo body models.LightReport true "Report category, and details (mandatory for the 'other' category)"
 // @Success 201 {object} models.Report
 // @Failure 400 {object} FormattedError
 // @Failure 401 {object} FormattedError
 // @Failure 404 {object} FormattedError
-// @Failure 409 {object} FormattedError "You can't report yourself"
+// @Failure 409 {object} FormattedError "You can't report yourself, or already have an open report about this user"
+// @Failure 429 {object} FormattedError "Daily reports quota exceeded"
 // @Router /users/{user_id}/report [post]
 func ReportsCreate(c buffalo.Context) error {
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -51,22 +56,70 @@ func ReportsCreate(c buffalo.Context) error {
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	if verrs.HasAny() {
+	switch {
+	case len(verrs.Get(models.ReportDuplicateKey)) > 0:
+		return c.Error(409, verrs)
+	case len(verrs.Get(models.ReportQuotaKey)) > 0:
+		return c.Error(429, verrs)
+	case verrs.HasAny():
 		return c.Error(400, verrs)
 	}
 
 	return c.Render(201, r.JSON(report))
 }
 
+// filterReports narrows a reports query down according to the "status",
+// "category", "assignee_id", "about_id", "by_id", "created_after" and
+// "created_before" parameters.
+func filterReports(c buffalo.Context, q *pop.Query) (*pop.Query, error) {
+	if status := c.Param("status"); status != "" {
+		if !models.ValidReportStatus(status) {
+			return nil, c.Error(400, errors.Errorf("Invalid status: %s", status))
+		}
+		q = q.Where("status = ?", status)
+	}
+	if category := c.Param("category"); category != "" {
+		if !models.ValidReportCategory(category) {
+			return nil, c.Error(400, errors.Errorf("Invalid category: %s", category))
+		}
+		q = q.Where("category = ?", category)
+	}
+	for _, field := range []string{"assignee_id", "about_id", "by_id"} {
+		value := c.Param(field)
+		if value == "" {
+			continue
+		}
+		id, err := uuid.FromString(value)
+		if err != nil {
+			return nil, c.Error(400, errors.Errorf("Invalid %s: %s", field, value))
+		}
+		q = q.Where(field+" = ?", id)
+	}
+	return filterCreated(c, q, "reports")
+}
+
 // ReportsList lists available reports
-// @Summary List available reports (requires admin credentials)
-// @Description List available reports (requires admin credentials)
+// @Summary List available reports (requires "reports:read" permission)
+// @Description List available reports (requires "reports:read" permission)
 // @security Bearer
 // @Produce  json
+// @Param status query string false "Only list reports with this status" Enums(open, in_review, resolved, dismissed)
+// @Param category query string false "Only list reports in this category" Enums(spam, harassment, impersonation, inappropriate_content, other)
+// @Param assignee_id query string false "Only list reports assigned to this moderator"
+// @Param about_id query string false "Only list reports about this user"
+// @Param by_id query string false "Only list reports filed by this user"
+// @Param created_after query string false "Only list reports filed after this date (RFC 3339)"
+// @Param created_before query string false "Only list reports filed before this date (RFC 3339)"
+// @Param sort query string false "Comma-separated sort fields among created_at, updated_at, resolved_at, status and category, prefixed with '-' for a descending order (default: created_at)"
 // @Param page query int false "Page number"
 // @Param per_page query int false "Items per page"
+// @Param after query string false "Cursor: list the reports after this one (only with the default order)"
+// @Param before query string false "Cursor: list the reports before this one (only with the default order)"
+// @Param limit query int false "Items per page, when using cursors"
 // @Success 200 {object} models.Reports
 // @Header 200  {object} X-Pagination "pagination information"
+// @Header 200  {string} Link "previous and next pages, when using cursors"
+// @Failure 400 {object} FormattedError
 // @Failure 401 {object} FormattedError
 // @Failure 403 {object} FormattedError
 // @Router /reports/ [get]
@@ -76,17 +129,278 @@ func ReportsList(c buffalo.Context) error {
 		return errors.WithStack(errors.New("no transaction found"))
 	}
 
-	auth := getCredentials(c)
-	if !auth.Admin {
-		return c.Error(403, errors.New("Forbidden"))
+	page, err := cursorPage(c)
+	if err != nil {
+		return err
+	}
+	q := tx.Q()
+	if page == nil {
+		q = tx.PaginateFromParams(c.Params())
 	}
 
 	reports := &models.Reports{}
-	q := tx.PaginateFromParams(c.Params())
+	q, err = filterReports(c, q)
+	if err != nil {
+		return err
+	}
+	order, err := sortOrder(c, "reports", "created_at", "updated_at", "resolved_at", "status", "category")
+	switch {
+	case err != nil:
+		return err
+	case page != nil && order != "":
+		return c.Error(400, errors.New("Cursors can't be used along with sort"))
+	case page != nil:
+		q = page.Apply(q, "reports.created_at", "reports.id", false)
+	case order != "":
+		q = q.Order(order)
+	default:
+		q = q.Order("reports.created_at, reports.id")
+	}
 	if err := q.Eager().All(reports); err != nil {
 		return errors.WithStack(err)
 	}
+	if page != nil {
+		prev, next := page.Trim(reports, func(i int) models.Cursor {
+			return models.Cursor{CreatedAt: (*reports)[i].CreatedAt, ID: (*reports)[i].ID}
+		})
+		setPageLinks(c, page, prev, next)
+	}
+	if err := reports.FetchDismissalRates(tx); err != nil {
+		return errors.WithStack(err)
+	}
+	err = audit(c, tx, &models.AuditEvent{
+		Action:     models.AuditReportsRead,
+		TargetType: models.AuditTargetReport,
+	})
+	if err != nil {
+		return err
+	}
 
-	c.Set("pagination", q.Paginator)
+	if page == nil {
+		c.Set("pagination", q.Paginator)
+	}
 	return c.Render(200, r.JSON(reports))
 }
+
+// ReportsQueue lists the reported users waiting for moderation
+// @Summary Moderation queue (requires "reports:read" permission)
+// @Description List the users with open reports: users who were flagged
+// @Description (because too many people reported them lately) come first,
+// @Description then by decreasing severity. The severity is the sum of the
+// @Description weights of the open reports' categories (harassment: 5,
+// @Description impersonation: 4, inappropriate_content: 3, spam: 2, other: 1).
+// @security Bearer
+// @Produce  json
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} models.Queue
+// @Header 200  {object} X-Pagination "pagination information"
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Router /reports/queue [get]
+func ReportsQueue(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	q := tx.PaginateFromParams(c.Params())
+	queue, err := models.FetchQueue(q)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("pagination", q.Paginator)
+	return c.Render(200, r.JSON(queue))
+}
+
+// ReportsCategories counts reports in each category
+// @Summary Count reports by category (requires "reports:read" permission)
+// @Description Count the reports in each category, optionally among the
+// @Description reports matching the same filters as the reports list.
+// @Description Categories without any report are left out.
+// @security Bearer
+// @Produce  json
+// @Param status query string false "Only count reports with this status" Enums(open, in_review, resolved, dismissed)
+// @Param assignee_id query string false "Only count reports assigned to this moderator"
+// @Param about_id query string false "Only count reports about this user"
+// @Param by_id query string false "Only count reports filed by this user"
+// @Param created_after query string false "Only count reports filed after this date (RFC 3339)"
+// @Param created_before query string false "Only count reports filed before this date (RFC 3339)"
+// @Success 200 {object} models.CategoryCounts
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Router /reports/categories [get]
+func ReportsCategories(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	q, err := filterReports(c, tx.Q())
+	if err != nil {
+		return err
+	}
+	counts, err := models.CountReportsByCategory(q)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(200, r.JSON(counts))
+}
+
+// findReport loads the report designated by the "report_id" parameter,
+// along with its reporter and reported user.
+func findReport(c buffalo.Context, tx *pop.Connection) (*models.Report, error) {
+	report := &models.Report{}
+	if err := tx.Eager().Find(report, c.Param("report_id")); err != nil {
+		return nil, c.Error(404, errors.New("Not Found"))
+	}
+	return report, nil
+}
+
+// ReportsShow shows a report
+// @Summary Show a report (requires "reports:read" permission)
+// @Description Show a report (requires "reports:read" permission)
+// @security Bearer
+// @Produce  json
+// @Param report_id path string true "The report ID"
+// @Success 200 {object} models.Report
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Router /reports/{report_id} [get]
+func ReportsShow(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	report, err := findReport(c, tx)
+	if err != nil {
+		return err
+	}
+	if err := models.FetchDismissalRates(tx, report); err != nil {
+		return errors.WithStack(err)
+	}
+	err = audit(c, tx, &models.AuditEvent{
+		Action:     models.AuditReportsRead,
+		TargetType: models.AuditTargetReport,
+		TargetID:   nulls.NewUUID(report.ID),
+	})
+	if err != nil {
+		return err
+	}
+	return c.Render(200, r.JSON(report))
+}
+
+// ReportsAssign assigns a report to a moderator
+// @Summary Assign a report (requires "reports:moderate" permission)
+// @Description Assign a report to a moderator (the caller by default),
+// @Description and put it in review.
+// @security Bearer
+// @Accept  json
+// @Produce  json
+// @Param report_id path string true "The report ID"
+// @Param assignment body models.ReportAssignment false "The assigned moderator"
+// @Success 200 {object} models.Report
+// @Failure 400 {object} FormattedError "The assignee can't moderate reports"
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Failure 409 {object} FormattedError "This report is already closed"
+// @Router /reports/{report_id}/assign [put]
+func ReportsAssign(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	auth := getCredentials(c)
+	if !auth.Can(models.PermReportsModerate) {
+		return c.Error(403, errors.New("Forbidden"))
+	}
+
+	report, err := findReport(c, tx)
+	if err != nil {
+		return err
+	}
+
+	assignment := &models.ReportAssignment{}
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(assignment); err != nil {
+			return c.Error(400, err)
+		}
+	}
+
+	assignee := auth
+	if assignment.AssigneeID != uuid.Nil && assignment.AssigneeID != auth.ID {
+		assignee = &models.User{}
+		if err := tx.Find(assignee, assignment.AssigneeID); err != nil {
+			return c.Error(400, errors.New("The assignee doesn't exist"))
+		}
+		if err := assignee.FetchRoles(tx); err != nil {
+			return errors.WithStack(err)
+		}
+		if !assignee.Can(models.PermReportsModerate) {
+			return c.Error(400, errors.New("The assignee can't moderate reports"))
+		}
+	}
+
+	err = report.Assign(tx, assignee.ID)
+	if err == models.ErrReportClosed {
+		return c.Error(409, err)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(200, r.JSON(report))
+}
+
+// ReportsResolve closes a report
+// @Summary Resolve a report (requires "reports:moderate" permission)
+// @Description Close a report as "resolved" (action was taken) or
+// @Description "dismissed" (no action was needed), with resolution notes.
+// @security Bearer
+// @Accept  json
+// @Produce  json
+// @Param report_id path string true "The report ID"
+// @Param resolution body models.ReportResolution true "Outcome of the report"
+// @Success 200 {object} models.Report
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Failure 409 {object} FormattedError "This report is already closed"
+// @Router /reports/{report_id}/resolve [put]
+func ReportsResolve(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	if !getCredentials(c).Can(models.PermReportsModerate) {
+		return c.Error(403, errors.New("Forbidden"))
+	}
+
+	report, err := findReport(c, tx)
+	if err != nil {
+		return err
+	}
+
+	resolution := &models.ReportResolution{}
+	if err := c.Bind(resolution); err != nil {
+		return c.Error(400, err)
+	}
+
+	err = report.Resolve(tx, resolution.Status, resolution.Resolution)
+	switch err {
+	case nil:
+		return c.Render(200, r.JSON(report))
+	case models.ErrInvalidResolution:
+		return c.Error(400, err)
+	case models.ErrReportClosed:
+		return c.Error(409, err)
+	}
+	return errors.WithStack(err)
+}
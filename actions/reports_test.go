@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/ArnaudCalmettes/microsocial/models"
+	"github.com/gobuffalo/envy"
 )
 
 func (as *ActionSuite) Test_Reports_Create() {
@@ -39,7 +40,7 @@ func (as *ActionSuite) Test_Reports_Create() {
 
 func (as *ActionSuite) Test_Reports_List() {
 	_, admin_token := as.createUserAndToken(true)
-	user, user_token := as.createUserAndToken(false)
+	_, user_token := as.createUserAndToken(false)
 	other := as.createRandomUser()
 
 	infos := []string{
@@ -53,7 +54,7 @@ func (as *ActionSuite) Test_Reports_List() {
 	// Create a bunch of reports
 	for _, info := range infos {
 		report := &models.Report{
-			ByID:    user.ID,
+			ByID:    as.createRandomUser().ID,
 			AboutID: other.ID,
 			Info:    info,
 		}
@@ -86,3 +87,262 @@ func (as *ActionSuite) Test_Reports_List() {
 
 	as.Equal(len(infos), len(reports))
 }
+
+func (as *ActionSuite) Test_Reports_List_Filtered() {
+	_, moderator_token := as.createUserWithRole(models.RoleModerator)
+	reporter := as.createRandomUser()
+
+	for _, category := range []string{models.CategorySpam, models.CategoryHarassment, models.CategoryOther} {
+		report := &models.Report{
+			ByID:     reporter.ID,
+			AboutID:  as.createRandomUser().ID,
+			Category: category,
+			Info:     "Details",
+		}
+		verrs, err := report.Create(as.DB)
+		as.NoError(err)
+		as.Falsef(verrs.HasAny(), verrs.String())
+	}
+	other := &models.Report{ByID: as.createRandomUser().ID, AboutID: reporter.ID, Category: models.CategorySpam}
+	verrs, err := other.Create(as.DB)
+	as.NoError(err)
+	as.Falsef(verrs.HasAny(), verrs.String())
+
+	list := func(query string) models.Reports {
+		resp := as.createAuthRequest("/reports?"+query, moderator_token).Get()
+		as.Equal(200, resp.Code)
+		reports := models.Reports{}
+		as.NoError(json.Unmarshal(resp.Body.Bytes(), &reports))
+		return reports
+	}
+
+	// Default order: by creation date
+	reports := list("")
+	as.Equal(4, len(reports))
+	as.Equal(other.ID, reports[3].ID)
+
+	as.Equal(3, len(list(fmt.Sprintf("by_id=%s", reporter.ID))))
+	as.Equal(4, len(list("created_after=2001-01-01T00:00:00Z")))
+	as.Empty(list("created_before=2001-01-01T00:00:00Z"))
+
+	reports = list("sort=-created_at")
+	as.Equal(other.ID, reports[0].ID)
+	reports = list("sort=category,-created_at")
+	as.Equal(models.CategoryHarassment, reports[0].Category)
+	as.Equal(other.ID, reports[2].ID)
+
+	for _, query := range []string{"by_id=nope", "created_before=now", "sort=info"} {
+		resp := as.createAuthRequest("/reports?"+query, moderator_token).Get()
+		as.Equalf(400, resp.Code, query)
+	}
+}
+
+func (as *ActionSuite) Test_Reports_Moderation() {
+	user, user_token := as.createUserAndToken(false)
+	other := as.createRandomUser()
+	moderator, moderator_token := as.createUserWithRole(models.RoleModerator)
+	_, support_token := as.createUserWithRole(models.RoleSupport)
+
+	report := &models.Report{ByID: user.ID, AboutID: other.ID, Info: "Spam"}
+	verrs, err := report.Create(as.DB)
+	as.NoError(err)
+	as.Falsef(verrs.HasAny(), verrs.String())
+	closed := &models.Report{ByID: other.ID, AboutID: user.ID, Info: "Nope"}
+	verrs, err = closed.Create(as.DB)
+	as.NoError(err)
+	as.Falsef(verrs.HasAny(), verrs.String())
+	as.NoError(closed.Resolve(as.DB, models.ReportDismissed, ""))
+
+	report_url := fmt.Sprintf("/reports/%s", report.ID)
+
+	// Show
+	resp := as.createAuthRequest(report_url, user_token).Get()
+	as.Equal(403, resp.Code)
+	resp = as.createAuthRequest(report_url, moderator_token).Get()
+	as.Equal(200, resp.Code)
+	as.Contains(resp.Body.String(), models.ReportOpen)
+
+	// Filters
+	reports := models.Reports{}
+	resp = as.createAuthRequest("/reports?status=open", moderator_token).Get()
+	as.Equal(200, resp.Code)
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &reports))
+	as.Equal(1, len(reports))
+	as.Equal(report.ID, reports[0].ID)
+
+	reports = models.Reports{}
+	resp = as.createAuthRequest(fmt.Sprintf("/reports?about_id=%s", user.ID), moderator_token).Get()
+	as.Equal(200, resp.Code)
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &reports))
+	as.Equal(1, len(reports))
+	as.Equal(closed.ID, reports[0].ID)
+
+	resp = as.createAuthRequest("/reports?status=whatever", moderator_token).Get()
+	as.Equal(400, resp.Code)
+	resp = as.createAuthRequest("/reports?assignee_id=whatever", moderator_token).Get()
+	as.Equal(400, resp.Code)
+
+	// Assign: only to moderators
+	resp = as.createAuthRequest(report_url+"/assign", user_token).Put(nil)
+	as.Equal(403, resp.Code)
+	resp = as.createAuthRequest(report_url+"/assign", moderator_token).Put(
+		map[string]string{"assignee_id": user.ID.String()},
+	)
+	as.Equal(400, resp.Code)
+	resp = as.createAuthRequest(report_url+"/assign", moderator_token).Put(nil)
+	as.Equal(200, resp.Code)
+
+	reports = models.Reports{}
+	resp = as.createAuthRequest(fmt.Sprintf("/reports?assignee_id=%s", moderator.ID), moderator_token).Get()
+	as.Equal(200, resp.Code)
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &reports))
+	as.Equal(1, len(reports))
+	as.Equal(models.ReportInReview, reports[0].Status)
+
+	// Resolve
+	resolution := map[string]string{"status": models.ReportResolved, "resolution": "Warned"}
+	resp = as.createAuthRequest(report_url+"/resolve", support_token).Put(resolution)
+	as.Equal(403, resp.Code)
+	resp = as.createAuthRequest(report_url+"/resolve", moderator_token).Put(
+		map[string]string{"status": models.ReportOpen},
+	)
+	as.Equal(400, resp.Code)
+	resp = as.createAuthRequest(report_url+"/resolve", moderator_token).Put(resolution)
+	as.Equal(200, resp.Code)
+
+	stored := &models.Report{}
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), stored))
+	as.Equal(models.ReportResolved, stored.Status)
+	as.Equal("Warned", stored.Resolution)
+	as.True(stored.ResolvedAt.Valid)
+
+	// Closed reports stay closed
+	resp = as.createAuthRequest(report_url+"/resolve", moderator_token).Put(resolution)
+	as.Equal(409, resp.Code)
+	resp = as.createAuthRequest(report_url+"/assign", moderator_token).Put(nil)
+	as.Equal(409, resp.Code)
+}
+
+func (as *ActionSuite) Test_Reports_Categories() {
+	_, user_token := as.createUserAndToken(false)
+	subject := as.createRandomUser()
+	_, moderator_token := as.createUserWithRole(models.RoleModerator)
+
+	url := fmt.Sprintf("/users/%s/report", subject.ID)
+
+	resp := as.createAuthRequest(url, user_token).Post(map[string]string{"category": "rudeness"})
+	as.Equal(400, resp.Code)
+	resp = as.createAuthRequest(url, user_token).Post(map[string]string{"category": models.CategoryOther})
+	as.Equal(400, resp.Code)
+
+	for _, category := range []string{models.CategorySpam, models.CategorySpam, models.CategoryImpersonation} {
+		_, reporter_token := as.createUserAndToken(false)
+		resp = as.createAuthRequest(url, reporter_token).Post(map[string]string{"category": category})
+		as.Equalf(201, resp.Code, resp.Body.String())
+	}
+
+	// Filtering
+	reports := models.Reports{}
+	resp = as.createAuthRequest("/reports?category=spam", moderator_token).Get()
+	as.Equal(200, resp.Code)
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &reports))
+	as.Equal(2, len(reports))
+	for _, r := range reports {
+		as.Equal(models.CategorySpam, r.Category)
+	}
+
+	resp = as.createAuthRequest("/reports?category=rudeness", moderator_token).Get()
+	as.Equal(400, resp.Code)
+
+	// Counts
+	resp = as.createAuthRequest("/reports/categories", user_token).Get()
+	as.Equal(403, resp.Code)
+
+	resp = as.createAuthRequest("/reports/categories?status=open", moderator_token).Get()
+	as.Equal(200, resp.Code)
+	counts := models.CategoryCounts{}
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &counts))
+	as.Equal(models.CategoryCounts{
+		{Category: models.CategoryImpersonation, Count: 1},
+		{Category: models.CategorySpam, Count: 2},
+	}, counts)
+}
+
+func (as *ActionSuite) Test_Reports_AntiAbuse() {
+	user, user_token := as.createUserAndToken(false)
+	subject := as.createRandomUser()
+	_, moderator_token := as.createUserWithRole(models.RoleModerator)
+	payload := map[string]string{"category": models.CategorySpam}
+
+	url := fmt.Sprintf("/users/%s/report", subject.ID)
+	resp := as.createAuthRequest(url, user_token).Post(payload)
+	as.Equal(201, resp.Code)
+
+	// Duplicate report
+	resp = as.createAuthRequest(url, user_token).Post(payload)
+	as.Equal(409, resp.Code)
+
+	// The previous reports of the reporter were all dismissed
+	old := &models.Report{ByID: user.ID, AboutID: as.createRandomUser().ID}
+	verrs, err := old.Create(as.DB)
+	as.NoError(err)
+	as.Falsef(verrs.HasAny(), verrs.String())
+	as.NoError(old.Resolve(as.DB, models.ReportDismissed, ""))
+
+	resp = as.createAuthRequest(fmt.Sprintf("/reports?about_id=%s", subject.ID), moderator_token).Get()
+	as.Equal(200, resp.Code)
+	reports := models.Reports{}
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &reports))
+	as.Equal(1, len(reports))
+	as.NotNil(reports[0].ReporterDismissalRate)
+	as.Equal(1.0, *reports[0].ReporterDismissalRate)
+
+	// Daily quota
+	envy.Set("REPORTS_DAILY_QUOTA", "2")
+	defer envy.Set("REPORTS_DAILY_QUOTA", "")
+
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s/report", as.createRandomUser().ID), user_token).Post(payload)
+	as.Equal(429, resp.Code)
+}
+
+func (as *ActionSuite) Test_Reports_Queue() {
+	envy.Set("ESCALATION_REPORT_THRESHOLD", "2")
+	defer envy.Set("ESCALATION_REPORT_THRESHOLD", "")
+
+	_, user_token := as.createUserAndToken(false)
+	_, moderator_token := as.createUserWithRole(models.RoleModerator)
+	flagged, flagged_token := as.createUserAndToken(false)
+	subject := as.createRandomUser()
+
+	resp := as.createAuthRequest(fmt.Sprintf("/users/%s/report", subject.ID), user_token).Post(
+		map[string]string{"category": models.CategoryHarassment},
+	)
+	as.Equal(201, resp.Code)
+
+	for i := 0; i < 2; i++ {
+		_, token := as.createUserAndToken(false)
+		resp = as.createAuthRequest(fmt.Sprintf("/users/%s/report", flagged.ID), token).Post(
+			map[string]string{"category": models.CategorySpam},
+		)
+		as.Equal(201, resp.Code)
+	}
+
+	resp = as.createAuthRequest("/reports/queue", user_token).Get()
+	as.Equal(403, resp.Code)
+
+	resp = as.createAuthRequest("/reports/queue", moderator_token).Get()
+	as.Equal(200, resp.Code)
+	queue := models.Queue{}
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &queue))
+	as.Equal(2, len(queue))
+	as.Equal(flagged.ID, queue[0].User.ID, "Flagged users come first")
+	as.True(queue[0].FlaggedAt.Valid)
+	as.Equal(subject.ID, queue[1].User.ID)
+	as.Equal(models.CategorySeverity[models.CategoryHarassment], queue[1].Severity)
+
+	// Flagged users can't send friend requests for a while
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s/friend_request", subject.ID), flagged_token).Post(
+		map[string]string{"message": "Hi!"},
+	)
+	as.Equal(403, resp.Code)
+}
@@ -0,0 +1,107 @@
+package actions
+
+import (
+	"github.com/ArnaudCalmettes/microsocial/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// findRoleTarget loads the user whose roles are being changed, after
+// checking that the caller is allowed to do so.
+func findRoleTarget(c buffalo.Context, tx *pop.Connection) (*models.User, models.Role, error) {
+	if !getCredentials(c).Can(models.PermUsersPromote) {
+		return nil, "", c.Error(403, errors.New("Forbidden"))
+	}
+
+	role := models.Role(c.Param("role"))
+	if !role.Valid() {
+		return nil, "", c.Error(400, models.ErrInvalidRole)
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return nil, "", c.Error(404, errors.New("Not Found"))
+	}
+	if err := user.FetchRoles(tx); err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	return user, role, nil
+}
+
+// UsersGrantRole grants a role to a user
+// @Summary Grant a role to a user (requires "users:promote" permission)
+// @Description Grant a role (support, moderator, admin) to a user.
+// @Description Auth tokens previously issued to the user are invalidated.
+// @security Bearer
+// @Produce  json
+// @Param user_id path string true "The user's ID"
+// @Param role path string true "The role" Enums(support, moderator, admin)
+// @Success 200 {object} models.User
+// @Failure 400 {object} FormattedError "Invalid role"
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Failure 500 {object} FormattedError
+// @Router /users/{user_id}/roles/{role} [put]
+func UsersGrantRole(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user, role, err := findRoleTarget(c, tx)
+	if err != nil {
+		return err
+	}
+
+	before := snapshotUser(user)
+	if err := user.GrantRole(tx, role); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := auditUser(c, tx, models.AuditUserGrantRole, before, user); err != nil {
+		return err
+	}
+
+	return c.Render(200, r.JSON(user))
+}
+
+// UsersRevokeRole revokes a role from a user
+// @Summary Revoke a role from a user (requires "users:promote" permission)
+// @Description Revoke a role (support, moderator, admin) from a user.
+// @Description Auth tokens previously issued to the user are invalidated.
+// @security Bearer
+// @Produce  json
+// @Param user_id path string true "The user's ID"
+// @Param role path string true "The role" Enums(support, moderator, admin)
+// @Success 200 {object} models.User
+// @Failure 400 {object} FormattedError "Invalid role"
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Failure 500 {object} FormattedError
+// @Router /users/{user_id}/roles/{role} [delete]
+func UsersRevokeRole(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user, role, err := findRoleTarget(c, tx)
+	if err != nil {
+		return err
+	}
+	if role == models.RoleUser {
+		return c.Error(400, errors.New("The user role can't be revoked"))
+	}
+
+	before := snapshotUser(user)
+	if err := user.RevokeRole(tx, role); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := auditUser(c, tx, models.AuditUserRevokeRole, before, user); err != nil {
+		return err
+	}
+
+	return c.Render(200, r.JSON(user))
+}
@@ -0,0 +1,113 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+)
+
+// createUserWithRole creates a random user with given role and generates
+// the related auth token.
+func (as *ActionSuite) createUserWithRole(role models.Role) (*models.User, string) {
+	user := as.createRandomUser()
+	err := user.GrantRole(as.DB, role)
+	as.NoError(err)
+	token, err := newToken(user, time.Minute)
+	as.NoError(err)
+	return user, token
+}
+
+func (as *ActionSuite) Test_Roles_Grant() {
+	_, admin_token := as.createUserAndToken(true)
+	user, user_token := as.createUserAndToken(false)
+	other, other_token := as.createUserAndToken(false)
+
+	url := fmt.Sprintf("/users/%s/roles/%s", user.ID, models.RoleModerator)
+
+	// Unauthorized
+	resp := as.JSON(url).Put(nil)
+	as.Equal(401, resp.Code)
+
+	// Users can't promote themselves
+	resp = as.createAuthRequest(url, user_token).Put(nil)
+	as.Equal(403, resp.Code)
+
+	// Unknown role
+	resp = as.createAuthRequest(
+		fmt.Sprintf("/users/%s/roles/overlord", user.ID), admin_token,
+	).Put(nil)
+	as.Equal(400, resp.Code)
+
+	// Unknown user
+	resp = as.createAuthRequest(
+		fmt.Sprintf("/users/%s/roles/moderator", "non-existent"), admin_token,
+	).Put(nil)
+	as.Equal(404, resp.Code)
+
+	// Admin promotes user to moderator
+	resp = as.createAuthRequest(url, admin_token).Put(nil)
+	as.Equal(200, resp.Code)
+
+	// The user needs a new token
+	resp = as.createAuthRequest("/reports", user_token).Get()
+	as.Equal(401, resp.Code)
+	err := as.DB.Reload(user)
+	as.NoError(err)
+	token, err := newToken(user, time.Minute)
+	as.NoError(err)
+
+	// Moderators can read reports...
+	resp = as.createAuthRequest("/reports", token).Get()
+	as.Equal(200, resp.Code)
+
+	report := &models.Report{ByID: user.ID, AboutID: other.ID, Info: "Spammer"}
+	verrs, err := report.Create(as.DB)
+	as.NoError(err)
+	as.Falsef(verrs.HasAny(), verrs.String())
+
+	profile := as.loadProfileAs(other, token)
+	as.NotEmpty(profile.Reports)
+	as.Empty(profile.Friends)
+
+	// ... but they can't edit other users...
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s", other.ID), token).Put(
+		map[string]string{"info": "Moderated"},
+	)
+	as.Equal(403, resp.Code)
+
+	// ... nor promote anybody
+	resp = as.createAuthRequest(
+		fmt.Sprintf("/users/%s/roles/moderator", other.ID), token,
+	).Put(nil)
+	as.Equal(403, resp.Code)
+
+	// Regular users still can't read reports
+	profile = as.loadProfileAs(other, other_token)
+	as.Empty(profile.Reports)
+	resp = as.createAuthRequest("/reports", other_token).Get()
+	as.Equal(403, resp.Code)
+}
+
+func (as *ActionSuite) Test_Roles_Revoke() {
+	_, admin_token := as.createUserAndToken(true)
+	user, _ := as.createUserWithRole(models.RoleModerator)
+
+	url := fmt.Sprintf("/users/%s/roles/%s", user.ID, models.RoleModerator)
+
+	// The implicit user role can't be revoked
+	resp := as.createAuthRequest(
+		fmt.Sprintf("/users/%s/roles/user", user.ID), admin_token,
+	).Delete()
+	as.Equal(400, resp.Code)
+
+	resp = as.createAuthRequest(url, admin_token).Delete()
+	as.Equal(200, resp.Code)
+
+	err := as.DB.Reload(user)
+	as.NoError(err)
+	token, err := newToken(user, time.Minute)
+	as.NoError(err)
+	resp = as.createAuthRequest("/reports", token).Get()
+	as.Equal(403, resp.Code)
+}
@@ -0,0 +1,170 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// enforceSanctions restricts the access of sanctioned users: banned users
+// can't do anything, and suspended users can only read. Anonymous requests
+// go through untouched. It must run after the auth middleware.
+func enforceSanctions(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		u := currentUser(c)
+		if u == nil {
+			return next(c)
+		}
+
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return errors.WithStack(errors.New("no transaction found"))
+		}
+
+		s, err := models.ActiveSanction(tx, u.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		switch {
+		case s == nil:
+		case s.Kind == models.SanctionBan:
+			return c.Error(403, errors.New("This account has been banned"))
+		case c.Request().Method != "GET":
+			return c.Error(403, errors.Errorf(
+				"This account is suspended until %s",
+				s.ExpiresAt.Time.Format(time.RFC3339),
+			))
+		}
+		return next(c)
+	}
+}
+
+// refuseBanned turns down banned users trying to obtain auth tokens
+func refuseBanned(c buffalo.Context, tx *pop.Connection, u *models.User) error {
+	s, err := models.ActiveSanction(tx, u.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if s != nil && s.Kind == models.SanctionBan {
+		return c.Error(403, errors.New("This account has been banned"))
+	}
+	return nil
+}
+
+// SanctionsCreate sanctions a user
+// @Summary Sanction a user (requires "users:sanction" permission)
+// @Description Warn, suspend (until expires_at) or ban a user, optionally
+// @Description because of a report about them. Suspended users can only
+// @Description read, banned users can't do anything nor log in.
+// @Description Sanctioning a user who has extra roles requires the
+// @Description "users:promote" permission as well.
+// @security Bearer
+// @Accept  json
+// @Produce  json
+// @Param user_id path string true "The user's ID"
+// @Param sanction body models.LightSanction true "The sanction"
+// @Success 201 {object} models.Sanction
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Router /users/{user_id}/sanctions [post]
+func SanctionsCreate(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	auth := getCredentials(c)
+	if !auth.Can(models.PermUsersSanction) {
+		return c.Error(403, errors.New("Forbidden"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return c.Error(404, errors.New("Not Found"))
+	}
+	if err := user.FetchRoles(tx); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Moderators can't sanction each other, or admins
+	if (user.Admin || len(user.Roles) > 0) && !auth.Can(models.PermUsersPromote) {
+		return c.Error(403, errors.New("Forbidden"))
+	}
+
+	light_sanction := &models.LightSanction{}
+	if err := c.Bind(light_sanction); err != nil {
+		return c.Error(400, err)
+	}
+	sanction := models.SanctionFromLight(light_sanction)
+	sanction.UserID = user.ID
+	sanction.ByID = nulls.NewUUID(auth.ID)
+
+	verrs, err := sanction.Create(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return c.Error(400, verrs)
+	}
+	err = audit(c, tx, &models.AuditEvent{
+		Action:     models.AuditSanctionCreate,
+		TargetType: models.AuditTargetSanction,
+		TargetID:   nulls.NewUUID(sanction.ID),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Render(201, r.JSON(sanction))
+}
+
+// SanctionsLift lifts a sanction before it expires
+// @Summary Lift a sanction (requires "users:sanction" permission)
+// @Description Lift a sanction before it expires
+// @security Bearer
+// @Produce  json
+// @Param sanction_id path string true "The sanction ID"
+// @Success 200 {object} models.Sanction
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Failure 409 {object} FormattedError "This sanction was already lifted"
+// @Router /sanctions/{sanction_id} [delete]
+func SanctionsLift(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	if !getCredentials(c).Can(models.PermUsersSanction) {
+		return c.Error(403, errors.New("Forbidden"))
+	}
+
+	sanction := &models.Sanction{}
+	if err := tx.Find(sanction, c.Param("sanction_id")); err != nil {
+		return c.Error(404, errors.New("Not Found"))
+	}
+
+	err := sanction.Lift(tx)
+	if err == models.ErrSanctionLifted {
+		return c.Error(409, err)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = audit(c, tx, &models.AuditEvent{
+		Action:     models.AuditSanctionLift,
+		TargetType: models.AuditTargetSanction,
+		TargetID:   nulls.NewUUID(sanction.ID),
+	})
+	if err != nil {
+		return err
+	}
+	return c.Render(200, r.JSON(sanction))
+}
@@ -0,0 +1,80 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ArnaudCalmettes/microsocial/models"
+)
+
+func (as *ActionSuite) Test_Sanctions() {
+	user, user_token := as.createUserAndToken(false)
+	other := as.createRandomUser()
+	_, moderator_token := as.createUserWithRole(models.RoleModerator)
+	admin, _ := as.createUserAndToken(true)
+
+	sanctions_url := fmt.Sprintf("/users/%s/sanctions", user.ID)
+	suspension := map[string]interface{}{
+		"kind":       models.SanctionSuspension,
+		"reason":     "Spamming",
+		"expires_at": time.Now().Add(time.Hour),
+	}
+
+	// Only moderators can sanction users
+	resp := as.createAuthRequest(sanctions_url, user_token).Post(suspension)
+	as.Equal(403, resp.Code)
+
+	// ... but not admins
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s/sanctions", admin.ID), moderator_token).Post(suspension)
+	as.Equal(403, resp.Code)
+
+	// Invalid sanction
+	resp = as.createAuthRequest(sanctions_url, moderator_token).Post(
+		map[string]string{"kind": "slap", "reason": "Spamming"},
+	)
+	as.Equal(400, resp.Code)
+
+	// Suspended users can still read, but not write
+	resp = as.createAuthRequest(sanctions_url, moderator_token).Post(suspension)
+	as.Equalf(201, resp.Code, resp.Body.String())
+
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s", other.ID), user_token).Get()
+	as.Equal(200, resp.Code)
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s/friend_request", other.ID), user_token).Post(nil)
+	as.Equal(403, resp.Code)
+
+	// They can see their sanctions on their profile
+	profile := as.loadProfileAs(user, user_token)
+	as.Equal(1, len(profile.Sanctions))
+
+	// Banned users can't do anything, nor log in
+	resp = as.createAuthRequest(sanctions_url, moderator_token).Post(
+		map[string]string{"kind": models.SanctionBan, "reason": "Spamming again"},
+	)
+	as.Equal(201, resp.Code)
+
+	ban := &models.Sanction{}
+	err := json.Unmarshal(resp.Body.Bytes(), ban)
+	as.NoError(err)
+
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s", other.ID), user_token).Get()
+	as.Equal(403, resp.Code)
+	resp = as.JSON("/auth/login").Post(LoginInfo{Login: user.Login, Password: user.Password})
+	as.Equal(403, resp.Code)
+	resp = as.JSON(fmt.Sprintf("/fake_auth/%s", user.Login)).Get()
+	as.Equal(403, resp.Code)
+
+	// Lifting the ban brings the user back to their suspension
+	sanction_url := fmt.Sprintf("/sanctions/%s", ban.ID)
+	resp = as.createAuthRequest(sanction_url, user_token).Delete()
+	as.Equal(403, resp.Code)
+	resp = as.createAuthRequest(sanction_url, moderator_token).Delete()
+	as.Equal(200, resp.Code)
+	resp = as.createAuthRequest(sanction_url, moderator_token).Delete()
+	as.Equal(409, resp.Code)
+
+	as.login(user.Login, user.Password)
+	resp = as.createAuthRequest(fmt.Sprintf("/users/%s", other.ID), user_token).Get()
+	as.Equal(200, resp.Code)
+}
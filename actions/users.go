@@ -1,20 +1,65 @@
 package actions
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/ArnaudCalmettes/microsocial/models"
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
 )
 
+// findVisibleUser loads the user designated by the "user_id" parameter,
+// optionally restricted to given columns. Users who blocked the caller are
+// reported as not found, so that the caller can't tell they were blocked.
+func findVisibleUser(c buffalo.Context, tx *pop.Connection, columns ...string) (*models.User, error) {
+	q := tx.Q()
+	if len(columns) > 0 {
+		q = q.Select(columns...)
+	}
+	user := &models.User{}
+	if err := q.Find(user, c.Param("user_id")); err != nil {
+		return nil, c.Error(404, errors.New("Not Found"))
+	}
+
+	blocked, err := models.IsBlocked(tx, user.ID, getCredentials(c).ID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if blocked {
+		return nil, c.Error(404, errors.New("Not Found"))
+	}
+	return user, nil
+}
+
 // UsersList lists all existing users
 // @Summary List all users
-// @Description List all existing users
+// @Description List all existing users. Authentication is optional: if a
+// @Description token is provided, users who blocked the caller are hidden.
+// @Description When a search term is given, only matching users are listed,
+// @Description most relevant first: logins are matched by prefix or
+// @Description similarity, and info by full-text search.
 // @Produce  json
+// @Param q query string false "Search term"
+// @Param admin query bool false "Only list admins (true) or non-admins (false)"
+// @Param created_after query string false "Only list users created after this date (RFC 3339)"
+// @Param created_before query string false "Only list users created before this date (RFC 3339)"
+// @Param sort query string false "Comma-separated sort fields among created_at, updated_at and login, prefixed with '-' for a descending order (default: created_at, or relevance when searching)"
 // @Param page query int false "Page number"
 // @Param per_page query int false "Items per page"
+// @Param after query string false "Cursor: list the users after this one (only with the default order)"
+// @Param before query string false "Cursor: list the users before this one (only with the default order)"
+// @Param limit query int false "Items per page, when using cursors"
+// @Param If-None-Match header string false "ETag of the page the client already has"
 // @Success 200 {object} models.Users
+// @Success 304 "Not Modified"
 // @Header 200  {object} X-Pagination "pagination information"
+// @Header 200  {string} Link "previous and next pages, when using cursors"
+// @Header 200  {string} ETag "version of the page"
+// @Failure 400 {object} FormattedError
 // @Failure 500 {object} FormattedError
 // @Router /users/ [get]
 func UsersList(c buffalo.Context) error {
@@ -26,8 +71,71 @@ func UsersList(c buffalo.Context) error {
 	users := &models.Users{}
 
 	// Paginate results. Params "page" and "per_page" control pagination.
-	// Default values are "page=1" and "per_page=20".
-	q := tx.PaginateFromParams(c.Params())
+	// Default values are "page=1" and "per_page=20". Cursors ("after",
+	// "before" and "limit") may be used instead.
+	page, err := cursorPage(c)
+	if err != nil {
+		return err
+	}
+	q := tx.Q()
+	if page == nil {
+		q = tx.PaginateFromParams(c.Params())
+	}
+
+	// Hide users who blocked the caller
+	if auth := currentUser(c); auth != nil {
+		q = q.Where("id NOT IN (SELECT blocker_id FROM blocks WHERE blocked_id = ?)", auth.ID)
+	}
+
+	q, err = filterCreated(c, q, "users")
+	if err != nil {
+		return err
+	}
+	if admin := c.Param("admin"); admin != "" {
+		b, err := strconv.ParseBool(admin)
+		if err != nil {
+			return c.Error(400, errors.Errorf("Invalid admin: %s", admin))
+		}
+		q = q.Where("users.admin = ?", b)
+	}
+
+	// Search users by login and info
+	term := strings.TrimSpace(c.Param("q"))
+	if term != "" {
+		q = models.SearchUsersQuery(q, term)
+	}
+
+	// Cursors only work with the default order
+	if page != nil {
+		if term != "" || c.Param("sort") != "" {
+			return c.Error(400, errors.New("Cursors can't be used along with q or sort"))
+		}
+		if err := page.Apply(q, "users.created_at", "users.id", false).All(users); err != nil {
+			return errors.WithStack(err)
+		}
+		prev, next := page.Trim(users, func(i int) models.Cursor {
+			return models.Cursor{CreatedAt: (*users)[i].CreatedAt, ID: (*users)[i].ID}
+		})
+		setPageLinks(c, page, prev, next)
+		if cachedUsers(c, users, nil) {
+			return nil
+		}
+		return c.Render(200, r.JSON(users))
+	}
+
+	// Explicit sort first, then by relevance when searching, and by
+	// creation date otherwise.
+	order, err := sortOrder(c, "users", "created_at", "updated_at", "login")
+	switch {
+	case err != nil:
+		return err
+	case order != "":
+		q = q.Order(order)
+	case term != "":
+		q = models.UsersByRelevance(q, term)
+	default:
+		q = q.Order("users.created_at, users.id")
+	}
 
 	if err := q.All(users); err != nil {
 		return errors.WithStack(err)
@@ -35,24 +143,74 @@ func UsersList(c buffalo.Context) error {
 
 	// Add X-Pagination header
 	c.Set("pagination", q.Paginator)
+	if cachedUsers(c, users, q.Paginator) {
+		return nil
+	}
 	return c.Render(200, r.JSON(users))
 }
 
+// cachedUsers handles the caching of a page of users. Its ETag depends on
+// the caller (users who blocked them are hidden), the request's parameters,
+// the pagination and the listed users. Pages don't have a modification
+// date, since users leaving a page can't be told by dates.
+func cachedUsers(c buffalo.Context, users *models.Users, paginator *pop.Paginator) bool {
+	auth := currentUser(c)
+	viewerID := uuid.Nil
+	if auth != nil {
+		viewerID = auth.ID
+	}
+	parts := []interface{}{viewerID, c.Request().URL.Query().Encode(), c.Response().Header().Get("Link")}
+	if paginator != nil {
+		parts = append(parts, paginator.TotalEntriesSize)
+	}
+	for _, u := range *users {
+		parts = append(parts, u.ID, u.UpdatedAt.UnixNano())
+	}
+	return cached(c, etag(parts...), time.Time{}, auth != nil)
+}
+
 // UsersShow shows all available information about a user.
 //
-// This call may get costly with time (in the worst case scenario,
-// it already performs 5 DB queries). However, this is a first iteration:
-// let's see how it behaves in prod before optimizing anything.
+// By default, every relation the caller is allowed to see is attached to
+// the profile. Friends, friend requests and reports are loaded in a single
+// query (see User.LoadProfile), but roles, sanctions and the number of
+// mutual friends still take a query each. Clients can ask for
+// leaner profiles with the "fields" and "expand" parameters: then, only the
+// requested columns are selected, and only the requested relations are
+// fetched.
 //
-// An obvious improvement may be to use a "If-Modified-Since" caching
-// strategy.
+// Profiles come with an ETag and a Last-Modified date, computed from the
+// latest changes to everything they are built from (see
+// models.FetchProfileVersion). Clients revalidating a profile they already
+// have get a "304 Not Modified", without the profile being loaded. The
+// version of the user alone, which updates are based on, is given
+// separately by the X-User-Version header.
 // @Summary Show a user's profile
-// @Description Show a detailed user profile.
+// @Description Show a detailed user profile. By default, it includes every
+// @Description relation the caller can see: friends and friend requests
+// @Description (for the user themselves, or with the "users:read"
+// @Description permission), reports ("reports:read"), and sanctions (for the
+// @Description user themselves, or with the "users:sanction" permission).
+// @Description If "fields" or "expand" is given, only the listed fields and
+// @Description relations are returned. Expanding a relation the caller can't
+// @Description see is forbidden.
+// @Description Profiles can be revalidated with the If-None-Match or
+// @Description If-Modified-Since headers.
 // @Produce  json
 // @security Bearer
 // @Param user_id path string true "ID of the user"
+// @Param fields query string false "Comma-separated fields among id, created_at, updated_at, login, info and admin"
+// @Param expand query string false "Comma-separated relations among roles, mutual_friend_count, friends, pending_requests, incoming_requests, reports and sanctions"
+// @Param If-None-Match header string false "ETag of the profile the client already has"
+// @Param If-Modified-Since header string false "Date of the profile the client already has"
 // @Success 200 {object} models.User
+// @Success 304 "Not Modified"
+// @Header 200 {string} ETag "version of the profile"
+// @Header 200 {string} Last-Modified "date of the latest change to the profile"
+// @Header 200 {string} X-User-Version "version of the user, for updates"
+// @Failure 400 {object} FormattedError
 // @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
 // @Failure 404 {object} FormattedError
 // @Failure 500 {object} FormattedError
 // @Router /users/{user_id} [get]
@@ -62,42 +220,104 @@ func UsersShow(c buffalo.Context) error {
 		return errors.WithStack(errors.New("No transaction found"))
 	}
 
-	user := &models.User{}
-	if err := tx.Find(user, c.Param("user_id")); err != nil {
-		return c.Error(404, err)
+	fields, err := listParam(c, "fields", userFields...)
+	if err != nil {
+		return err
+	}
+	expand, err := listParam(c, "expand", userRelationNames()...)
+	if err != nil {
+		return err
 	}
 
-	auth := getCredentials(c)
-
-	// Add extra (friends & friend requests) info
-	if auth.ID == user.ID || auth.Admin {
-		if err := user.FetchFriends(tx); err != nil {
-			return c.Error(500, err)
-		}
-		if err := user.FetchRequests(tx); err != nil {
-			return c.Error(500, err)
+	// The ID is always needed to fetch relations, and the lock version to
+	// tell which version of the user updates must be based on.
+	columns := fields
+	if fields != nil {
+		columns = append([]string{"lock_version"}, fields...)
+		if !contains(fields, "id") {
+			columns = append(columns, "id")
 		}
 	}
+	user, err := findVisibleUser(c, tx, columns...)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("X-User-Version", userTag(user))
 
-	// Add extra moderation info
-	if auth.Admin {
-		if err := user.FetchReports(tx); err != nil {
-			return c.Error(500, err)
-		}
+	// Don't load the profile if the client already has it
+	tag, modified, personalized, err := profileTag(c, tx, user, fields, expand)
+	if err != nil {
+		return err
+	}
+	if cached(c, tag, modified, personalized) {
+		return nil
 	}
 
-	return c.Render(200, r.JSON(user))
+	if err := expandUser(c, tx, user, fields, expand); err != nil {
+		return err
+	}
+
+	if fields == nil {
+		return c.Render(200, r.JSON(user))
+	}
+	sparse, err := sparseUser(user, append(fields, expand...))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Render(200, r.JSON(sparse))
+}
 
+// profileTag computes the ETag and the modification date of a user's
+// profile, with given fields and relations, as seen by the caller. It also
+// tells whether the profile is personalized. The ETag depends on the
+// version of the profile, on who's asking, if anybody, and on the query, so
+// that representations with different fields and relations don't share it.
+func profileTag(c buffalo.Context, tx *pop.Connection, user *models.User, fields, expand []string) (string, time.Time, bool, error) {
+	auth := getCredentials(c)
+	personalized := personalizedUser(auth, user, fields, expand)
+	viewerID := uuid.Nil
+	if personalized {
+		viewerID = auth.ID
+	}
+	version, err := models.FetchProfileVersion(tx, user.ID, viewerID)
+	if err != nil {
+		return "", time.Time{}, false, errors.WithStack(err)
+	}
+	tag := etag(user.ID, viewerID, c.Request().URL.Query().Encode(), version.ModifiedAt.UnixNano(), version.Count)
+	return tag, version.ModifiedAt, personalized, nil
+}
+
+// userTag computes the entity tag of a user's row, which updates must be
+// based on. Unlike the ETag of their profile, it only changes when the user
+// themselves is modified (see models.User.Update).
+func userTag(user *models.User) string {
+	return etag(user.ID, user.LockVersion)
+}
+
+// checkIfMatch makes sure that a user is updated based on its current
+// version, as given by the If-Match header (see userTag). Changes to the
+// rest of the profile, such as new friends or reports, don't matter.
+func checkIfMatch(c buffalo.Context, user *models.User) error {
+	header := c.Request().Header.Get("If-Match")
+	if header == "" {
+		return c.Error(428, errors.New("Precondition Required: If-Match header is missing"))
+	}
+	if !matchTag(header, userTag(user), false) {
+		return c.Error(412, errors.New("Precondition Failed: the user was modified in the meantime"))
+	}
+	return nil
 }
 
 // UsersCreate creates a new user
 // @Summary Create a new user
-// @Description Creates a new user
+// @Description Creates a new user. Anybody can sign up, but only callers
+// @Description with the "users:promote" permission can create admins.
 // @Accept  json
 // @Produce  json
-// @Param userinfo body models.LightUser true "login (mandatory), info, admin"
+// @Param userinfo body models.LightUser true "login (mandatory), password (mandatory), info, admin"
 // @Success 201 {object} models.User
 // @Failure 400 {object} FormattedError
+// @Failure 403 {object} FormattedError "Only admins can create admins"
 // @Failure 409 {object} FormattedError "The login is already taken"
 // @Router /users/ [post]
 func UsersCreate(c buffalo.Context) error {
@@ -111,6 +331,14 @@ func UsersCreate(c buffalo.Context) error {
 		return c.Error(400, err)
 	}
 
+	// Signing up is public, but creating an admin is a promotion
+	if light_user.Admin {
+		auth := currentUser(c)
+		if auth == nil || !auth.Can(models.PermUsersPromote) {
+			return c.Error(403, errors.New("I see what you did there!"))
+		}
+	}
+
 	user := models.UserFromLight(light_user)
 	verrs, err := user.Create(tx)
 	if err != nil {
@@ -120,22 +348,50 @@ func UsersCreate(c buffalo.Context) error {
 		return c.Error(409, verrs)
 	}
 
+	if user.Admin {
+		before := snapshotUser(user)
+		before.Admin = false
+		if err := auditUser(c, tx, models.AuditUserPromote, before, user); err != nil {
+			return err
+		}
+	}
+
 	return c.Render(201, r.JSON(user))
 }
 
-// UsersUpdate updates user information
+// userUpdate holds the fields of a user that can be modified with PUT. The
+// ID is only there to be checked against the one of the URL.
+type userUpdate struct {
+	ID    uuid.UUID `json:"id"`
+	Login string    `json:"login"`
+	Info  string    `json:"info"`
+	Admin bool      `json:"admin"`
+}
+
+// UsersUpdate updates user information.
+//
+// Updates must be based on the current version of the user, as given by
+// the If-Match header, so that concurrent edits don't silently overwrite each
+// other.
 // @Summary Update a user's information
-// @Description Update a user's information
+// @Description Update a user's information. The If-Match header must give
+// @Description the version of the user (the X-User-Version header returned
+// @Description by GET /users/{user_id} or by the previous update): if the
+// @Description user was modified in the meantime, the update is rejected.
 // @security Bearer
 // @Accept  json
 // @Produce  json
 // @Param user_id path string true "The user ID"
+// @Param If-Match header string true "Version of the user the update is based on"
 // @Param userinfo body models.LightUser true "New user information"
 // @Success 200 {object} models.User
+// @Header 200 {string} X-User-Version "new version of the user"
 // @Failure 400 {object} FormattedError
 // @Failure 401 {object} FormattedError
 // @Failure 403 {object} FormattedError
 // @Failure 409 {object} FormattedError
+// @Failure 412 {object} FormattedError
+// @Failure 428 {object} FormattedError
 // @Router /users/{user_id} [put]
 func UsersUpdate(c buffalo.Context) error {
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -143,36 +399,126 @@ func UsersUpdate(c buffalo.Context) error {
 		return errors.WithStack(errors.New("no transaction found"))
 	}
 
-	user := &models.User{}
-	if err := tx.Find(user, c.Param("user_id")); err != nil {
-		return c.Error(404, errors.New("Not Found"))
-	}
-
-	auth := getCredentials(c)
-	if auth.ID != user.ID && !auth.Admin {
-		return c.Error(403, errors.New("Forbidden"))
+	user, err := findEditableUser(c, tx)
+	if err != nil {
+		return err
 	}
 
-	if err := c.Bind(user); err != nil {
+	// Only the writable fields are bound: missing ones are left as is
+	before := snapshotUser(user)
+	update := &userUpdate{ID: user.ID, Login: user.Login, Info: user.Info, Admin: user.Admin}
+	if err := c.Bind(update); err != nil {
 		return c.Error(400, err)
 	}
+	if update.ID != user.ID {
+		return c.Error(400, errors.New("The user's ID can't be modified"))
+	}
+	user.Login, user.Info, user.Admin = update.Login, update.Info, update.Admin
 
 	// Prevent users from escalating their own privileges.
 	// Only admins can do that.
-	if user.Admin && !auth.Admin {
+	if user.Admin && !getCredentials(c).Can(models.PermUsersPromote) {
 		return c.Error(403, errors.New("I see what you did there!"))
 	}
 
+	return saveUser(c, tx, before, user)
+}
+
+// findEditableUser loads the user designated by the "user_id" parameter,
+// provided that the caller can edit it, and that the If-Match header gives
+// its current version.
+func findEditableUser(c buffalo.Context, tx *pop.Connection) (*models.User, error) {
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return nil, c.Error(404, errors.New("Not Found"))
+	}
+
+	auth := getCredentials(c)
+	if auth.ID != user.ID && !auth.Can(models.PermUsersUpdate) {
+		return nil, c.Error(403, errors.New("Forbidden"))
+	}
+	if err := checkIfMatch(c, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// saveUser saves the changes made to a user, audits them if they are
+// privileged, and renders the updated user along with its new version.
+func saveUser(c buffalo.Context, tx *pop.Connection, before, user *models.User) error {
 	verrs, err := user.Update(tx)
+	if errors.Cause(err) == models.ErrStaleUser {
+		return c.Error(412, err)
+	}
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	if verrs.HasAny() {
 		return c.Error(409, verrs)
 	}
+
+	// Keep track of privileged edits
+	switch {
+	case user.Admin != before.Admin:
+		err = auditUser(c, tx, models.AuditUserPromote, before, user)
+	case user.ID != getCredentials(c).ID:
+		err = auditUser(c, tx, models.AuditUserUpdate, before, user)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Give the new version of the user, for further updates
+	c.Response().Header().Set("X-User-Version", userTag(user))
 	return c.Render(200, r.JSON(user))
 }
 
+// UsersPatch partially updates user information
+// @Summary Partially update a user's information
+// @Description Update some of a user's information with a JSON merge patch
+// @Description (RFC 7396): only the fields present in the patch are
+// @Description modified, and null clears the info. Only login, info and admin
+// @Description (with the "users:promote" permission) can be modified: any
+// @Description other field is rejected. As with PUT, the If-Match header must
+// @Description give the version of the user.
+// @security Bearer
+// @Accept  json
+// @Produce  json
+// @Param user_id path string true "The user ID"
+// @Param If-Match header string true "Version of the user the update is based on"
+// @Param patch body models.LightUser true "Fields to modify"
+// @Success 200 {object} models.User
+// @Header 200 {string} X-User-Version "new version of the user"
+// @Failure 400 {object} FormattedError
+// @Failure 401 {object} FormattedError
+// @Failure 403 {object} FormattedError
+// @Failure 404 {object} FormattedError
+// @Failure 409 {object} FormattedError
+// @Failure 412 {object} FormattedError
+// @Failure 428 {object} FormattedError
+// @Router /users/{user_id} [patch]
+func UsersPatch(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user, err := findEditableUser(c, tx)
+	if err != nil {
+		return err
+	}
+
+	patch, err := readMergePatch(c)
+	if err != nil {
+		return err
+	}
+	before := snapshotUser(user)
+	if err := patchUser(c, user, patch); err != nil {
+		return err
+	}
+	return saveUser(c, tx, before, user)
+}
+
 // UsersDestroy deletes a user from the DB
 // @Summary Deletes a user.
 // @Description Deletes a user
@@ -195,12 +541,17 @@ func UsersDestroy(c buffalo.Context) error {
 	}
 
 	auth := getCredentials(c)
-	if auth.ID != user.ID && !auth.Admin {
+	if auth.ID != user.ID && !auth.Can(models.PermUsersDelete) {
 		return c.Error(403, errors.New("Forbidden"))
 	}
 
 	if err := tx.Destroy(user); err != nil {
 		return errors.WithStack(err)
 	}
+	if user.ID != auth.ID {
+		if err := auditUser(c, tx, models.AuditUserDelete, user, nil); err != nil {
+			return err
+		}
+	}
 	return c.Render(200, r.JSON(user))
 }
@@ -3,6 +3,7 @@ package actions
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/ArnaudCalmettes/microsocial/models"
@@ -39,6 +40,7 @@ func (as *ActionSuite) loadProfileAs(user *models.User, token string) *models.Us
 func (as *ActionSuite) createRandomUser() *models.User {
 	u := &models.User{}
 	gofakeit.Struct(u)
+	u.Password = gofakeit.Password(true, true, true, false, false, 12)
 	verrs, err := u.Create(as.DB)
 	as.NoError(err)
 	as.Falsef(verrs.HasAny(), verrs.String())
@@ -77,6 +79,16 @@ func (as *ActionSuite) createAuthRequest(url string, token string) *httptest.JSO
 	return req
 }
 
+// createUpdateRequest prepares an update of the user at given URL, based on
+// its current version. A lean profile is loaded to get it, so that no
+// relation is read (and audited) along the way.
+func (as *ActionSuite) createUpdateRequest(url string, token string) *httptest.JSON {
+	tag := as.createAuthRequest(url+"?fields=id", token).Get().Header().Get("X-User-Version")
+	req := as.createAuthRequest(url, token)
+	req.Headers["If-Match"] = tag
+	return req
+}
+
 func (as *ActionSuite) Test_Users_List() {
 	expected := as.createRandomUsers(4)
 
@@ -89,6 +101,157 @@ func (as *ActionSuite) Test_Users_List() {
 	as.checkUsers(expected, actual)
 }
 
+func (as *ActionSuite) Test_Users_Search() {
+	for login, info := range map[string]string{
+		"Alice":  "Live from Wonderland",
+		"Alicia": "Loves gardening",
+		"Bob":    "Not a sponge",
+	} {
+		u := &models.User{Login: login, Info: info, Password: "password1234"}
+		verrs, err := u.Create(as.DB)
+		as.NoError(err)
+		as.Falsef(verrs.HasAny(), verrs.String())
+	}
+
+	resp := as.JSON("/users?q=ali&per_page=1").Get()
+	as.Equal(200, resp.Code)
+	as.Contains(resp.Header().Get("X-Pagination"), `"total_entries_size":2`)
+
+	users := models.Users{}
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &users))
+	as.Equal(1, len(users))
+	as.Equal("Alice", users[0].Login, "Best match first")
+
+	resp = as.JSON("/users?q=sponge").Get()
+	as.Equal(200, resp.Code)
+	users = models.Users{}
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &users))
+	as.Equal(1, len(users))
+	as.Equal("Bob", users[0].Login)
+}
+
+func (as *ActionSuite) Test_Users_List_Filtered() {
+	admin, _ := as.createUserAndToken(true)
+	users := as.createRandomUsers(3)
+	old := users[2]
+	err := as.DB.RawQuery(
+		"UPDATE users SET created_at = ? WHERE id = ?",
+		time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), old.ID,
+	).Exec()
+	as.NoError(err)
+
+	list := func(query string) models.Users {
+		resp := as.JSON("/users?" + query).Get()
+		as.Equal(200, resp.Code)
+		users := models.Users{}
+		as.NoError(json.Unmarshal(resp.Body.Bytes(), &users))
+		return users
+	}
+
+	// Default order: by creation date
+	all := list("")
+	as.Equal(4, len(all))
+	as.Equal(old.ID, all[0].ID)
+
+	// Filters
+	admins := list("admin=true")
+	as.Equal(1, len(admins))
+	as.Equal(admin.ID, admins[0].ID)
+	as.Equal(3, len(list("admin=false")))
+
+	before := list("created_before=2001-01-01T00:00:00Z")
+	as.Equal(1, len(before))
+	as.Equal(old.ID, before[0].ID)
+	as.Equal(3, len(list("created_after=2001-01-01T00:00:00Z")))
+
+	// Sort
+	sorted := list("sort=-login")
+	as.Equal(4, len(sorted))
+	for i := 1; i < len(sorted); i++ {
+		as.True(sorted[i-1].Login >= sorted[i].Login)
+	}
+	sorted = list("sort=-created_at,login")
+	as.Equal(old.ID, sorted[3].ID)
+
+	// Invalid parameters
+	for _, query := range []string{
+		"admin=maybe",
+		"created_after=yesterday",
+		"sort=password_hash",
+	} {
+		resp := as.JSON("/users?" + query).Get()
+		as.Equalf(400, resp.Code, query)
+	}
+}
+
+// pageLink extracts the URL of the page with given relation from a Link
+// header, or returns an empty string.
+func pageLink(header, rel string) string {
+	m := regexp.MustCompile(`<([^>]*)>; rel="` + rel + `"`).FindStringSubmatch(header)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+func (as *ActionSuite) Test_Users_List_Cursors() {
+	expected := as.createRandomUsers(5)
+
+	// Walk forwards through pages of 2 users
+	actual := models.Users{}
+	url := "/users?limit=2"
+	for pages := 0; url != ""; pages++ {
+		as.True(pages < 3, "Too many pages")
+		resp := as.JSON(url).Get()
+		as.Equal(200, resp.Code)
+		users := models.Users{}
+		as.NoError(json.Unmarshal(resp.Body.Bytes(), &users))
+		actual = append(actual, users...)
+		url = pageLink(resp.Header().Get("Link"), "next")
+
+		// Inserting users before the cursor doesn't shift the next pages
+		if pages == 0 {
+			err := as.DB.RawQuery(
+				"UPDATE users SET created_at = ? WHERE id = ?",
+				time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), as.createRandomUser().ID,
+			).Exec()
+			as.NoError(err)
+		}
+	}
+	as.Equal(len(expected), len(actual))
+	for i := range expected {
+		as.Equal(expected[i].ID, actual[i].ID)
+	}
+
+	// ... then backwards
+	resp := as.JSON(fmt.Sprintf("/users?limit=2&after=%s", models.Cursor{
+		CreatedAt: actual[3].CreatedAt, ID: actual[3].ID,
+	})).Get()
+	as.Equal(200, resp.Code)
+	url = pageLink(resp.Header().Get("Link"), "prev")
+	as.NotEmpty(url)
+	resp = as.JSON(url).Get()
+	as.Equal(200, resp.Code)
+	users := models.Users{}
+	as.NoError(json.Unmarshal(resp.Body.Bytes(), &users))
+	as.Equal(2, len(users))
+	as.Equal(expected[2].ID, users[0].ID)
+	as.Equal(expected[3].ID, users[1].ID)
+
+	// Invalid parameters
+	for _, query := range []string{
+		"after=garbage",
+		"limit=0",
+		"limit=1000",
+		"limit=2&page=2",
+		"limit=2&q=bob",
+		"limit=2&sort=login",
+	} {
+		resp := as.JSON("/users?" + query).Get()
+		as.Equalf(400, resp.Code, query)
+	}
+}
+
 func (as *ActionSuite) Test_Users_List_Paginated() {
 	var results [10]models.User
 
@@ -140,19 +303,103 @@ func (as *ActionSuite) Test_Users_Show() {
 	as.Equal(user.Info, actual.Info)
 }
 
+func (as *ActionSuite) Test_Users_Show_Sparse() {
+	user, user_token := as.createUserAndToken(false)
+	_, other_token := as.createUserAndToken(false)
+	_, moderator_token := as.createUserWithRole(models.RoleModerator)
+	friend := as.createRandomUser()
+	as.makeFriends(user, friend)
+
+	show := func(token, query string) map[string]json.RawMessage {
+		resp := as.createAuthRequest(fmt.Sprintf("/users/%s?%s", user.ID, query), token).Get()
+		as.Equal(200, resp.Code)
+		profile := map[string]json.RawMessage{}
+		as.NoError(json.Unmarshal(resp.Body.Bytes(), &profile))
+		return profile
+	}
+	keys := func(profile map[string]json.RawMessage) []string {
+		keys := []string{}
+		for k := range profile {
+			keys = append(keys, k)
+		}
+		return keys
+	}
+
+	// Only the requested fields
+	profile := show(user_token, "fields=login,info")
+	as.ElementsMatch([]string{"login", "info"}, keys(profile))
+	as.Equal(fmt.Sprintf("%q", user.Login), string(profile["login"]))
+
+	// ... and relations
+	profile = show(user_token, "fields=id&expand=friends")
+	as.ElementsMatch([]string{"id", "friends"}, keys(profile))
+	friends := models.Users{}
+	as.NoError(json.Unmarshal(profile["friends"], &friends))
+	as.Equal(1, len(friends))
+	as.Equal(friend.ID, friends[0].ID)
+
+	// Relations without restricting fields
+	profile = show(user_token, "expand=incoming_requests")
+	as.Contains(profile, "login")
+	as.NotContains(profile, "friends")
+
+	// Nothing is fetched unless asked for
+	profile = show(other_token, "fields=login")
+	as.NotContains(profile, "mutual_friend_count")
+	profile = show(other_token, "expand=mutual_friend_count")
+	as.Equal("0", string(profile["mutual_friend_count"]))
+
+	// Visibility rules still apply
+	for _, query := range []string{"expand=friends", "expand=reports", "fields=id&expand=sanctions"} {
+		resp := as.createAuthRequest(fmt.Sprintf("/users/%s?%s", user.ID, query), other_token).Get()
+		as.Equalf(403, resp.Code, query)
+	}
+	show(moderator_token, "expand=reports")
+
+	// Unknown fields and relations
+	for _, query := range []string{"fields=password_hash", "expand=everything"} {
+		resp := as.createAuthRequest(fmt.Sprintf("/users/%s?%s", user.ID, query), user_token).Get()
+		as.Equalf(400, resp.Code, query)
+	}
+}
+
 func (as *ActionSuite) Test_Users_Create() {
 	// Insufficient data
 	req := as.JSON("/users")
 	resp := req.Post(map[string]string{})
 	as.Equal(409, resp.Code)
 
+	// Password is too short
+	resp = req.Post(map[string]string{"login": "toto", "password": "toto"})
+	as.Equal(409, resp.Code)
+
 	// Valid request
-	resp = req.Post(map[string]string{"login": "toto"})
+	resp = req.Post(map[string]string{"login": "toto", "password": "correct horse"})
 	as.Equal(201, resp.Code)
+	as.NotContains(resp.Body.String(), "password")
+	as.NotContains(resp.Body.String(), "correct horse")
 
 	// Already exists
-	resp = req.Post(map[string]string{"login": "toto"})
+	resp = req.Post(map[string]string{"login": "toto", "password": "battery staple"})
 	as.Equal(409, resp.Code)
+
+	// Only admins can create admins, which is audited
+	admin := map[string]interface{}{"login": "boss", "password": "correct horse", "admin": true}
+	resp = req.Post(admin)
+	as.Equal(403, resp.Code)
+	_, user_token := as.createUserAndToken(false)
+	resp = as.createAuthRequest("/users", user_token).Post(admin)
+	as.Equal(403, resp.Code)
+
+	_, admin_token := as.createUserAndToken(true)
+	resp = as.createAuthRequest("/users", admin_token).Post(admin)
+	as.Equal(201, resp.Code)
+	created := &models.User{}
+	as.NoError(as.DB.Where("login = ?", "boss").First(created))
+	as.True(created.Admin)
+	events := as.auditEvents(admin_token, "action="+models.AuditUserPromote)
+	as.Equal(1, len(events))
+	as.Equal(created.ID, events[0].TargetID.UUID)
 }
 
 func (as *ActionSuite) Test_Users_Update() {
@@ -181,14 +428,13 @@ func (as *ActionSuite) Test_Users_Update() {
 	// Use authorized user credentials
 	token, err = newToken(user, time.Minute)
 	as.NoError(err)
-	req = as.createAuthRequest(url, token)
 
 	// No modification
-	resp = req.Put(map[string]string{})
+	resp = as.createUpdateRequest(url, token).Put(map[string]string{})
 	as.Equal(200, resp.Code)
 
 	// Modifying info
-	resp = req.Put(map[string]string{"info": "Some offensive stuff"})
+	resp = as.createUpdateRequest(url, token).Put(map[string]string{"info": "Some offensive stuff"})
 	as.Equal(200, resp.Code)
 	actual := &models.User{}
 	err = as.DB.Find(actual, user.ID)
@@ -196,36 +442,43 @@ func (as *ActionSuite) Test_Users_Update() {
 	as.Equal("Some offensive stuff", actual.Info)
 
 	// Trying to steal an existing login
-	resp = req.Put(map[string]string{"login": other.Login})
+	resp = as.createUpdateRequest(url, token).Put(map[string]string{"login": other.Login})
 	as.Equal(409, resp.Code)
 
 	// Try to escalate privileges
-	resp = req.Put(map[string]bool{"admin": true})
+	resp = as.createUpdateRequest(url, token).Put(map[string]bool{"admin": true})
 	as.Equal(403, resp.Code)
 
+	// Try to overwrite another user through the body
+	resp = as.createUpdateRequest(url, token).Put(map[string]interface{}{"id": other.ID, "info": "Hijacked"})
+	as.Equal(400, resp.Code)
+	stolen := &models.User{}
+	as.NoError(as.DB.Find(stolen, other.ID))
+	as.Equal(other.Info, stolen.Info)
+	as.Equal(other.PasswordHash, stolen.PasswordHash)
+
 	// Use admin credentials
 	token, err = newToken(admin, time.Minute)
 	as.NoError(err)
-	req = as.createAuthRequest(url, token)
 
 	// Passivate the user's offensive information
-	resp = req.Put(map[string]string{"info": "<Judge Dredd has pacified this info>"})
+	resp = as.createUpdateRequest(url, token).Put(map[string]string{"info": "<Judge Dredd has pacified this info>"})
 	as.Equal(200, resp.Code)
 	err = as.DB.Find(actual, user.ID)
 	as.NoError(err)
 	as.Equal("<Judge Dredd has pacified this info>", actual.Info)
 
 	// Promote user to admin
-	resp = req.Put(map[string]bool{"admin": true})
+	resp = as.createUpdateRequest(url, token).Put(map[string]bool{"admin": true})
 	as.Equal(200, resp.Code)
 
 	// Evil admin tries to change the user's login to another, existing one
-	resp = req.Put(map[string]string{"login": other.Login})
+	resp = as.createUpdateRequest(url, token).Put(map[string]string{"login": other.Login})
 	as.Equal(409, resp.Code)
 
 	// Finally, try to modify a user that doesn't exist
 	url = fmt.Sprintf("/users/%s", "non-existent")
-	req = as.createAuthRequest(url, token)
+	req = as.createUpdateRequest(url, token)
 	resp = req.Put(map[string]string{"info": "<Judge Dredd has pacified this info>"})
 	as.Equal(404, resp.Code)
 }
@@ -278,3 +531,219 @@ func (as *ActionSuite) Test_Users_Delete() {
 	resp = req.Delete()
 	as.Equal(200, resp.Code)
 }
+
+func (as *ActionSuite) Test_Users_Show_Cache() {
+	user, user_token := as.createUserAndToken(false)
+	_, other_token := as.createUserAndToken(false)
+	url := fmt.Sprintf("/users/%s", user.ID)
+
+	resp := as.createAuthRequest(url, user_token).Get()
+	as.Equal(200, resp.Code)
+	tag := resp.Header().Get("ETag")
+	modified := resp.Header().Get("Last-Modified")
+	as.NotEmpty(tag)
+	as.NotEmpty(modified)
+	as.Equal("private, no-cache", resp.Header().Get("Cache-Control"))
+	as.Contains(resp.Header().Get("Vary"), "Authorization")
+
+	// Revalidation
+	req := as.createAuthRequest(url, user_token)
+	req.Headers["If-None-Match"] = tag
+	resp = req.Get()
+	as.Equal(304, resp.Code)
+	as.Empty(resp.Body.String())
+	as.Equal(tag, resp.Header().Get("ETag"))
+
+	req = as.createAuthRequest(url, user_token)
+	req.Headers["If-Modified-Since"] = modified
+	as.Equal(304, req.Get().Code)
+
+	// A new friend request changes the profile
+	other := as.createRandomUser()
+	_, err := other.SendRequest(as.DB, user, "")
+	as.NoError(err)
+	req = as.createAuthRequest(url, user_token)
+	req.Headers["If-None-Match"] = tag
+	resp = req.Get()
+	as.Equal(200, resp.Code)
+	as.NotEqual(tag, resp.Header().Get("ETag"))
+
+	// ... and so does losing a friend
+	friend := as.createRandomUser()
+	as.makeFriends(user, friend)
+	tag = as.createAuthRequest(url, user_token).Get().Header().Get("ETag")
+	fs := &models.Friendship{UserID: user.ID, FriendID: friend.ID}
+	as.NoError(fs.Destroy(as.DB))
+	req = as.createAuthRequest(url, user_token)
+	req.Headers["If-None-Match"] = tag
+	as.Equal(200, req.Get().Code)
+
+	// Other users' profiles are personalized, unless restricted to fields
+	// that don't depend on who's asking.
+	resp = as.createAuthRequest(url, other_token).Get()
+	as.Equal("private, no-cache", resp.Header().Get("Cache-Control"))
+	as.NotEqual(tag, resp.Header().Get("ETag"))
+
+	resp = as.createAuthRequest(url+"?fields=login,info", other_token).Get()
+	as.Equal(200, resp.Code)
+	as.Equal("public, no-cache", resp.Header().Get("Cache-Control"))
+	shared := resp.Header().Get("ETag")
+
+	req = as.createAuthRequest(url+"?fields=login,info", user_token)
+	req.Headers["If-None-Match"] = shared
+	as.Equal(200, req.Get().Code, "The user's own profile isn't shared")
+
+	_, third_token := as.createUserAndToken(false)
+	req = as.createAuthRequest(url+"?fields=login,info", third_token)
+	req.Headers["If-None-Match"] = shared
+	as.Equal(304, req.Get().Code)
+
+	req = as.createAuthRequest(url+"?fields=login", third_token)
+	req.Headers["If-None-Match"] = shared
+	as.Equal(200, req.Get().Code)
+}
+
+func (as *ActionSuite) Test_Users_List_Cache() {
+	as.createRandomUsers(3)
+
+	resp := as.JSON("/users").Get()
+	as.Equal(200, resp.Code)
+	tag := resp.Header().Get("ETag")
+	as.NotEmpty(tag)
+	as.Equal("public, no-cache", resp.Header().Get("Cache-Control"))
+
+	req := as.JSON("/users")
+	req.Headers["If-None-Match"] = tag
+	as.Equal(304, req.Get().Code)
+
+	req = as.JSON("/users?per_page=2")
+	req.Headers["If-None-Match"] = tag
+	as.Equal(200, req.Get().Code)
+
+	// New users change the list
+	as.createRandomUser()
+	req = as.JSON("/users")
+	req.Headers["If-None-Match"] = tag
+	as.Equal(200, req.Get().Code)
+
+	// Users who blocked the caller are hidden, so lists are personalized
+	_, token := as.createUserAndToken(false)
+	resp = as.createAuthRequest("/users", token).Get()
+	as.Equal(200, resp.Code)
+	as.Equal("private, no-cache", resp.Header().Get("Cache-Control"))
+}
+
+func (as *ActionSuite) Test_Users_Update_Concurrency() {
+	user, user_token := as.createUserAndToken(false)
+	_, admin_token := as.createUserAndToken(true)
+	url := fmt.Sprintf("/users/%s", user.ID)
+
+	// The If-Match header is mandatory
+	resp := as.createAuthRequest(url, user_token).Put(map[string]string{"info": "Blind"})
+	as.Equal(428, resp.Code)
+
+	// Both the user and an admin load the profile...
+	mine := as.createUpdateRequest(url, user_token)
+	theirs := as.createAuthRequest(url, admin_token)
+	resp = as.createAuthRequest(url, admin_token).Get()
+	theirs.Headers["If-Match"] = resp.Header().Get("X-User-Version")
+	as.NotEqual(resp.Header().Get("ETag"), theirs.Headers["If-Match"])
+
+	// ... changes to the rest of the profile don't matter...
+	other := as.createRandomUser()
+	_, err := other.SendRequest(as.DB, user, "")
+	as.NoError(err)
+
+	// ... the first update goes through...
+	resp = mine.Put(map[string]string{"login": user.Login, "info": "Mine"})
+	as.Equal(200, resp.Code)
+	tag := resp.Header().Get("X-User-Version")
+	as.NotEmpty(tag)
+
+	// ... but the second one would overwrite it
+	resp = theirs.Put(map[string]string{"login": user.Login, "info": "Theirs"})
+	as.Equal(412, resp.Code)
+	stored := &models.User{}
+	as.NoError(as.DB.Find(stored, user.ID))
+	as.Equal("Mine", stored.Info)
+	as.Equal(1, stored.LockVersion)
+
+	// The version given by the response can be used for the next update
+	req := as.createAuthRequest(url, user_token)
+	req.Headers["If-Match"] = tag
+	resp = req.Put(map[string]string{"login": user.Login, "info": "Mine again"})
+	as.Equal(200, resp.Code)
+
+	// Weak tags don't match
+	req = as.createAuthRequest(url, user_token)
+	req.Headers["If-Match"] = "W/" + resp.Header().Get("X-User-Version")
+	as.Equal(412, req.Put(map[string]string{"info": "Weak"}).Code)
+}
+
+func (as *ActionSuite) Test_Users_Patch() {
+	user, user_token := as.createUserAndToken(false)
+	_, admin_token := as.createUserAndToken(true)
+	other := as.createRandomUser()
+	url := fmt.Sprintf("/users/%s", user.ID)
+
+	stored := func() *models.User {
+		u := &models.User{}
+		as.NoError(as.DB.Find(u, user.ID))
+		return u
+	}
+
+	// The If-Match header is mandatory
+	resp := as.createAuthRequest(url, user_token).Patch(map[string]string{"info": "Blind"})
+	as.Equal(428, resp.Code)
+
+	// Only the given fields are modified
+	resp = as.createUpdateRequest(url, user_token).Patch(map[string]string{"info": "Patched"})
+	as.Equal(200, resp.Code)
+	as.NotEmpty(resp.Header().Get("X-User-Version"))
+	as.Equal("Patched", stored().Info)
+	as.Equal(user.Login, stored().Login)
+
+	// Unknown and read-only fields are rejected, along with the whole patch
+	resp = as.createUpdateRequest(url, user_token).Patch(map[string]interface{}{
+		"info":       "Sneaky",
+		"id":         other.ID,
+		"created_at": time.Now(),
+		"friends":    []string{},
+		"password":   "12345678",
+	})
+	as.Equal(400, resp.Code)
+	body := resp.Body.String()
+	as.Contains(body, "created_at is read-only, friends is read-only, id is read-only, password is unknown")
+	as.Equal("Patched", stored().Info)
+
+	// Only admins can modify the admin flag
+	resp = as.createUpdateRequest(url, user_token).Patch(map[string]bool{"admin": true})
+	as.Equal(400, resp.Code)
+	as.Contains(resp.Body.String(), "admin requires the users:promote permission")
+	as.False(stored().Admin)
+
+	// Values must have the right type, and only info can be removed
+	resp = as.createUpdateRequest(url, user_token).Patch(map[string]interface{}{"login": 42})
+	as.Equal(400, resp.Code)
+	as.Contains(resp.Body.String(), "login has the wrong type")
+	resp = as.createUpdateRequest(url, user_token).Patch(map[string]interface{}{"login": nil})
+	as.Equal(400, resp.Code)
+	as.Contains(resp.Body.String(), "login can't be removed")
+	resp = as.createUpdateRequest(url, user_token).Patch(map[string]interface{}{"info": nil})
+	as.Equal(200, resp.Code)
+	as.Equal("", stored().Info)
+
+	// Patches must be JSON objects
+	resp = as.createUpdateRequest(url, user_token).Patch([]string{"info"})
+	as.Equal(400, resp.Code)
+
+	// Logins are still unique
+	resp = as.createUpdateRequest(url, user_token).Patch(map[string]string{"login": other.Login})
+	as.Equal(409, resp.Code)
+
+	// Admins can promote users
+	resp = as.createUpdateRequest(url, admin_token).Patch(map[string]bool{"admin": true})
+	as.Equal(200, resp.Code)
+	as.True(stored().Admin)
+	as.Equal(user.Login, stored().Login)
+}
@@ -1,38 +1,305 @@
-// GENERATED BY THE COMMAND ABOVE; DO NOT EDIT
-// This file was generated by swaggo/swag at
-// 2019-09-21 23:40:41.853466402 +0200 CEST m=+0.306385612
+// Code generated by swaggo/swag. DO NOT EDIT.
 
 package docs
 
-import (
-	"bytes"
-	"encoding/json"
-	"strings"
+import "github.com/swaggo/swag"
 
-	"github.com/alecthomas/template"
-	"github.com/swaggo/swag"
-)
-
-var doc = `{
+const docTemplate = `{
     "schemes": {{ marshal .Schemes }},
     "swagger": "2.0",
     "info": {
-        "description": "{{.Description}}",
+        "description": "{{escape .Description}}",
         "title": "{{.Title}}",
         "contact": {},
-        "license": {},
         "version": "{{.Version}}"
     },
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/audit/": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "List the trace of privileged actions, most recent first.\nAudit events can't be modified nor deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List audit events (requires \"audit:read\" permission)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only list actions performed by this user",
+                        "name": "actor_id",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "user.update",
+                            "user.promote",
+                            "user.delete",
+                            "user.grant_role",
+                            "user.revoke_role",
+                            "sanction.create",
+                            "sanction.lift",
+                            "reports.read"
+                        ],
+                        "type": "string",
+                        "description": "Only list this kind of action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "user",
+                            "report",
+                            "sanction"
+                        ],
+                        "type": "string",
+                        "description": "Only list actions on this kind of object",
+                        "name": "target_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list actions on this object",
+                        "name": "target_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list actions performed after this date (RFC 3339)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list actions performed before this date (RFC 3339)",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page",
+                        "name": "per_page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AuditEvent"
+                            }
+                        },
+                        "headers": {
+                            "X-Pagination": {
+                                "type": "object",
+                                "description": "pagination information"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/login": {
+            "post": {
+                "description": "Get a short-lived Bearer token and a refresh token by\nproviding a login and password",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Log in",
+                "parameters": [
+                    {
+                        "description": "login and password",
+                        "name": "credentials",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/actions.LoginInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/actions.TokenPair"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid login or password",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "This account has been banned",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/logout": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Revoke the current access token. If a refresh token is\nprovided, all the tokens of its family are revoked as well.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Log out",
+                "parameters": [
+                    {
+                        "description": "refresh token",
+                        "name": "refresh_token",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/actions.RefreshInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/refresh": {
+            "post": {
+                "description": "Exchange a refresh token for a new access token and a new\nrefresh token. Reusing a refresh token that was already\nrotated revokes all the tokens derived from the same login.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Refresh auth tokens",
+                "parameters": [
+                    {
+                        "description": "refresh token",
+                        "name": "refresh_token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/actions.RefreshInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/actions.TokenPair"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid, expired or revoked refresh token",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "This account has been banned",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
         "/fake_auth/{user_login}": {
             "get": {
-                "description": "Get Bearer token for given user",
+                "description": "Get Bearer token for given user, without checking any password.\nOnly available in development and test environments.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get Bearer token for given user",
+                "summary": "Get Bearer token for given user (development only)",
                 "parameters": [
                     {
                         "type": "string",
@@ -52,7 +319,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/string"
+                            "type": "string"
                         }
                     },
                     "400": {
@@ -61,6 +328,12 @@ var doc = `{
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
+                    "403": {
+                        "description": "This account has been banned",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -76,23 +349,1418 @@ var doc = `{
                 }
             }
         },
-        "/friend_requests/{request_id}/accept": {
+        "/friend_requests/": {
             "get": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "description": "Accept a friend request",
+                "description": "List the caller's pending incoming (default) or outgoing friend requests",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List pending friend requests",
+                "parameters": [
+                    {
+                        "enum": [
+                            "in",
+                            "out"
+                        ],
+                        "type": "string",
+                        "description": "Incoming or outgoing requests",
+                        "name": "direction",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page",
+                        "name": "per_page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.FriendRequest"
+                            }
+                        },
+                        "headers": {
+                            "X-Pagination": {
+                                "type": "object",
+                                "description": "pagination information"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/friend_requests/{request_id}": {
+            "delete": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Cancel a pending friend request (only its sender can)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cancel a friend request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The friend request ID",
+                        "name": "request_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.FriendRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "This request isn't yours to cancel",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "409": {
+                        "description": "This friend request isn't pending anymore",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/friend_requests/{request_id}/accept": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Accept a friend request",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Accept a friend request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The friend request ID",
+                        "name": "request_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.FriendRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "This request isn't yours to accept",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "409": {
+                        "description": "This friend request isn't pending anymore",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/friend_requests/{request_id}/decline": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Decline a friend request",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Decline a friend request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The friend request ID",
+                        "name": "request_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.FriendRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "This request isn't yours to decline",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "409": {
+                        "description": "This friend request isn't pending anymore",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/suggestions": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "List the friends of the caller's friends, ranked by the number\nof mutual friends, with a few of these mutual friends.\nFriends, users with a pending friend request to or from the\ncaller and blocked users are never suggested.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "People you may know",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page",
+                        "name": "per_page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Suggestion"
+                            }
+                        },
+                        "headers": {
+                            "X-Pagination": {
+                                "type": "object",
+                                "description": "pagination information"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "List available reports (requires \"reports:read\" permission)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List available reports (requires \"reports:read\" permission)",
+                "parameters": [
+                    {
+                        "enum": [
+                            "open",
+                            "in_review",
+                            "resolved",
+                            "dismissed"
+                        ],
+                        "type": "string",
+                        "description": "Only list reports with this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "spam",
+                            "harassment",
+                            "impersonation",
+                            "inappropriate_content",
+                            "other"
+                        ],
+                        "type": "string",
+                        "description": "Only list reports in this category",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list reports assigned to this moderator",
+                        "name": "assignee_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list reports about this user",
+                        "name": "about_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list reports filed by this user",
+                        "name": "by_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list reports filed after this date (RFC 3339)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list reports filed before this date (RFC 3339)",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated sort fields among created_at, updated_at, resolved_at, status and category, prefixed with '-' for a descending order (default: created_at)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page",
+                        "name": "per_page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor: list the reports after this one (only with the default order)",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor: list the reports before this one (only with the default order)",
+                        "name": "before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page, when using cursors",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Report"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "previous and next pages, when using cursors"
+                            },
+                            "X-Pagination": {
+                                "type": "object",
+                                "description": "pagination information"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/categories": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Count the reports in each category, optionally among the\nreports matching the same filters as the reports list.\nCategories without any report are left out.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Count reports by category (requires \"reports:read\" permission)",
+                "parameters": [
+                    {
+                        "enum": [
+                            "open",
+                            "in_review",
+                            "resolved",
+                            "dismissed"
+                        ],
+                        "type": "string",
+                        "description": "Only count reports with this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only count reports assigned to this moderator",
+                        "name": "assignee_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only count reports about this user",
+                        "name": "about_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only count reports filed by this user",
+                        "name": "by_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only count reports filed after this date (RFC 3339)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only count reports filed before this date (RFC 3339)",
+                        "name": "created_before",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.CategoryCount"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/queue": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "List the users with open reports: users who were flagged\n(because too many people reported them lately) come first,\nthen by decreasing severity. The severity is the sum of the\nweights of the open reports' categories (harassment: 5,\nimpersonation: 4, inappropriate_content: 3, spam: 2, other: 1).",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Moderation queue (requires \"reports:read\" permission)",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page",
+                        "name": "per_page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.QueueEntry"
+                            }
+                        },
+                        "headers": {
+                            "X-Pagination": {
+                                "type": "object",
+                                "description": "pagination information"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/{report_id}": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Show a report (requires \"reports:read\" permission)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Show a report (requires \"reports:read\" permission)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The report ID",
+                        "name": "report_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Report"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/{report_id}/assign": {
+            "put": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Assign a report to a moderator (the caller by default),\nand put it in review.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Assign a report (requires \"reports:moderate\" permission)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The report ID",
+                        "name": "report_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The assigned moderator",
+                        "name": "assignment",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReportAssignment"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Report"
+                        }
+                    },
+                    "400": {
+                        "description": "The assignee can't moderate reports",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "409": {
+                        "description": "This report is already closed",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/{report_id}/resolve": {
+            "put": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Close a report as \"resolved\" (action was taken) or\n\"dismissed\" (no action was needed), with resolution notes.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Resolve a report (requires \"reports:moderate\" permission)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The report ID",
+                        "name": "report_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Outcome of the report",
+                        "name": "resolution",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ReportResolution"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "409": {
+                        "description": "This report is already closed",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/sanctions/{sanction_id}": {
+            "delete": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Lift a sanction before it expires",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Lift a sanction (requires \"users:sanction\" permission)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The sanction ID",
+                        "name": "sanction_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Sanction"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "409": {
+                        "description": "This sanction was already lifted",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/": {
+            "get": {
+                "description": "List all existing users. Authentication is optional: if a\ntoken is provided, users who blocked the caller are hidden.\nWhen a search term is given, only matching users are listed,\nmost relevant first: logins are matched by prefix or\nsimilarity, and info by full-text search.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List all users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search term",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only list admins (true) or non-admins (false)",
+                        "name": "admin",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list users created after this date (RFC 3339)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list users created before this date (RFC 3339)",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated sort fields among created_at, updated_at and login, prefixed with '-' for a descending order (default: created_at, or relevance when searching)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page",
+                        "name": "per_page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor: list the users after this one (only with the default order)",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor: list the users before this one (only with the default order)",
+                        "name": "before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page, when using cursors",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the page the client already has",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.User"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "version of the page"
+                            },
+                            "Link": {
+                                "type": "string",
+                                "description": "previous and next pages, when using cursors"
+                            },
+                            "X-Pagination": {
+                                "type": "object",
+                                "description": "pagination information"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a new user. Anybody can sign up, but only callers\nwith the \"users:promote\" permission can create admins.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create a new user",
+                "parameters": [
+                    {
+                        "description": "login (mandatory), password (mandatory), info, admin",
+                        "name": "userinfo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LightUser"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Only admins can create admins",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "409": {
+                        "description": "The login is already taken",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{user_id}": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Show a detailed user profile. By default, it includes every\nrelation the caller can see: friends and friend requests\n(for the user themselves, or with the \"users:read\"\npermission), reports (\"reports:read\"), and sanctions (for the\nuser themselves, or with the \"users:sanction\" permission).\nIf \"fields\" or \"expand\" is given, only the listed fields and\nrelations are returned. Expanding a relation the caller can't\nsee is forbidden.\nProfiles can be revalidated with the If-None-Match or\nIf-Modified-Since headers.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Show a user's profile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the user",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields among id, created_at, updated_at, login, info and admin",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated relations among roles, mutual_friend_count, friends, pending_requests, incoming_requests, reports and sanctions",
+                        "name": "expand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the profile the client already has",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date of the profile the client already has",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "version of the profile"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "date of the latest change to the profile"
+                            },
+                            "X-User-Version": {
+                                "type": "string",
+                                "description": "version of the user, for updates"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Update a user's information. The If-Match header must give\nthe version of the user (the X-User-Version header returned\nby GET /users/{user_id} or by the previous update): if the\nuser was modified in the meantime, the update is rejected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update a user's information",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The user ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Version of the user the update is based on",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "New user information",
+                        "name": "userinfo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LightUser"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        },
+                        "headers": {
+                            "X-User-Version": {
+                                "type": "string",
+                                "description": "new version of the user"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Deletes a user",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Deletes a user.",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Update some of a user's information with a JSON merge patch\n(RFC 7396): only the fields present in the patch are\nmodified, and null clears the info. Only login, info and admin\n(with the \"users:promote\" permission) can be modified: any\nother field is rejected. As with PUT, the If-Match header must\ngive the version of the user.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Partially update a user's information",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The user ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Version of the user the update is based on",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to modify",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LightUser"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        },
+                        "headers": {
+                            "X-User-Version": {
+                                "type": "string",
+                                "description": "new version of the user"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{user_id}/block": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Block a user: any friendship or pending friend request\nbetween both users is removed, they can't request each other\nas friends anymore, and the blocked user can't see the caller.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Block a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The user's ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Block"
+                        }
+                    },
+                    "400": {
+                        "description": "Can't block yourself",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Unblock a user",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Accept a friend request",
+                "summary": "Unblock a user",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "The friend request ID",
-                        "name": "request_id",
+                        "description": "The user's ID",
+                        "name": "user_id",
                         "in": "path",
                         "required": true
                     }
@@ -101,7 +1769,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.FriendRequest"
+                            "type": "string"
                         }
                     },
                     "401": {
@@ -110,12 +1778,6 @@ var doc = `{
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
-                    "403": {
-                        "description": "This request isn't yours to accept",
-                        "schema": {
-                            "$ref": "#/definitions/actions.FormattedError"
-                        }
-                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -131,34 +1793,58 @@ var doc = `{
                 }
             }
         },
-        "/friend_requests/{request_id}/decline": {
-            "get": {
+        "/users/{user_id}/friend_request": {
+            "post": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "description": "Decline a friend request",
+                "description": "Send a friend request to a user. If this user already sent\na pending friend request to the caller, it is automatically\naccepted and an actions.AutoAcceptedRequest is returned\ninstead, with a 201 status since a friendship was created.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Decline a friend request",
+                "summary": "Send a friend request to a user",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "The friend request ID",
-                        "name": "request_id",
+                        "description": "The user's ID",
+                        "name": "user_id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "message associated to the friend request",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LightFriendRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "The new friend request",
                         "schema": {
                             "$ref": "#/definitions/models.FriendRequest"
                         }
                     },
+                    "201": {
+                        "description": "The crossing request was accepted",
+                        "schema": {
+                            "$ref": "#/definitions/actions.AutoAcceptedRequest"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
@@ -166,7 +1852,7 @@ var doc = `{
                         }
                     },
                     "403": {
-                        "description": "This request isn't yours to decline",
+                        "description": "The caller was reported too often lately",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
@@ -177,8 +1863,8 @@ var doc = `{
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "409": {
+                        "description": "You can't request yourself as a friend",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
@@ -186,23 +1872,81 @@ var doc = `{
                 }
             }
         },
-        "/reports/": {
+        "/users/{user_id}/friends": {
             "get": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "description": "List available reports (requires admin credentials)",
+                "description": "List a user's friends, most recent friendships first. Only\nusers themselves (or users with the \"users:read\" permission)\ncan see their friends. Cursors (\"after\" or \"before\", and\n\"limit\") can be used instead of page numbers.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "List available reports (requires admin credentials)",
+                "summary": "List a user's friends",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The user's ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page",
+                        "name": "per_page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor: list the friends after this one",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor: list the friends before this one",
+                        "name": "before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page, when using cursors",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Reports"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.User"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "previous and next pages, when using cursors"
+                            },
+                            "X-Pagination": {
+                                "type": "object",
+                                "description": "pagination information"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
                     "401": {
@@ -216,22 +1960,57 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
                     }
                 }
             }
         },
-        "/users/": {
+        "/users/{user_id}/mutual_friends": {
             "get": {
-                "description": "List all existing users",
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "List the friends the caller and another user have in common",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "List all users",
+                "summary": "List mutual friends",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The user's ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page",
+                        "name": "per_page",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Users"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.User"
+                            }
                         },
                         "headers": {
                             "X-Pagination": {
@@ -240,50 +2019,20 @@ var doc = `{
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
-                    }
-                }
-            },
-            "post": {
-                "description": "Creates a new user",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "summary": "Create a new user",
-                "parameters": [
-                    {
-                        "description": "login (mandatory), info, admin",
-                        "name": "userinfo",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "object",
-                            "$ref": "#/definitions/actions.LightUser"
-                        }
-                    }
-                ],
-                "responses": {
-                    "201": {
-                        "description": "Created",
-                        "schema": {
-                            "$ref": "#/definitions/models.User"
-                        }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
-                    "409": {
-                        "description": "The login is already taken",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
@@ -291,32 +2040,48 @@ var doc = `{
                 }
             }
         },
-        "/users/{user_id}": {
+        "/users/{user_id}/path": {
             "get": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "description": "Show a detailed user profile.",
+                "description": "Show the shortest chain of friends from a user to another,\nboth included. Users on either side of a block with the caller\nare never part of the chain. The chain can't be longer than\nFRIEND_PATH_MAX_DEPTH friendships (6 by default).\nOnly the caller's own connections can be looked up, unless\nthey have the users:read permission.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Show a user's profile",
+                "summary": "Show how two users are connected",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID of the user",
+                        "description": "ID of the first user",
                         "name": "user_id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the last user",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.User"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.User"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
                     "401": {
@@ -325,8 +2090,14 @@ var doc = `{
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
                     "404": {
-                        "description": "Not Found",
+                        "description": "No path was found",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
@@ -338,45 +2109,46 @@ var doc = `{
                         }
                     }
                 }
-            },
-            "put": {
+            }
+        },
+        "/users/{user_id}/report": {
+            "post": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "description": "Update a user's information",
+                "description": "Report a user to the moderators. Users can only have one\nopen report about somebody, and can't file more than\nREPORTS_DAILY_QUOTA reports (10 by default) within 24 hours.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update a user's information",
+                "summary": "Report a user to the moderators",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "The user ID",
+                        "description": "The user's ID",
                         "name": "user_id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "New user information",
+                        "description": "Report category, and details (mandatory for the 'other' category)",
                         "name": "userinfo",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "object",
-                            "$ref": "#/definitions/actions.LightUser"
+                            "$ref": "#/definitions/models.LightReport"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.User"
+                            "$ref": "#/definitions/models.Report"
                         }
                     },
                     "400": {
@@ -391,31 +2163,60 @@ var doc = `{
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
-                    "403": {
-                        "description": "Forbidden",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
                     "409": {
-                        "description": "Conflict",
+                        "description": "You can't report yourself, or already have an open report about this user",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
+                    "429": {
+                        "description": "Daily reports quota exceeded",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/users/{user_id}/roles/{role}": {
+            "put": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "description": "Deletes a user",
+                "description": "Grant a role (support, moderator, admin) to a user.\nAuth tokens previously issued to the user are invalidated.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Deletes a user.",
+                "summary": "Grant a role to a user (requires \"users:promote\" permission)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The user's ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "support",
+                            "moderator",
+                            "admin"
+                        ],
+                        "type": "string",
+                        "description": "The role",
+                        "name": "role",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -423,6 +2224,12 @@ var doc = `{
                             "$ref": "#/definitions/models.User"
                         }
                     },
+                    "400": {
+                        "description": "Invalid role",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
@@ -440,25 +2247,26 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
                     }
                 }
-            }
-        },
-        "/users/{user_id}/friend_request": {
-            "post": {
+            },
+            "delete": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "description": "Send a friend request to a user",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Revoke a role (support, moderator, admin) from a user.\nAuth tokens previously issued to the user are invalidated.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Send a friend request to a user",
+                "summary": "Revoke a role from a user (requires \"users:promote\" permission)",
                 "parameters": [
                     {
                         "type": "string",
@@ -468,25 +2276,27 @@ var doc = `{
                         "required": true
                     },
                     {
-                        "description": "message associated to the friend request",
-                        "name": "message",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "object",
-                            "$ref": "#/definitions/actions.LightFriendRequest"
-                        }
+                        "enum": [
+                            "support",
+                            "moderator",
+                            "admin"
+                        ],
+                        "type": "string",
+                        "description": "The role",
+                        "name": "role",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.FriendRequest"
+                            "$ref": "#/definitions/models.User"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid role",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
@@ -497,14 +2307,20 @@ var doc = `{
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/actions.FormattedError"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
-                    "409": {
-                        "description": "You can't request yourself as a friend",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
@@ -512,21 +2328,21 @@ var doc = `{
                 }
             }
         },
-        "/users/{user_id}/report": {
+        "/users/{user_id}/sanctions": {
             "post": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "description": "Report a user to the moderators",
+                "description": "Warn, suspend (until expires_at) or ban a user, optionally\nbecause of a report about them. Suspended users can only\nread, banned users can't do anything nor log in.\nSanctioning a user who has extra roles requires the\n\"users:promote\" permission as well.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Report a user to the moderators",
+                "summary": "Sanction a user (requires \"users:sanction\" permission)",
                 "parameters": [
                     {
                         "type": "string",
@@ -536,13 +2352,12 @@ var doc = `{
                         "required": true
                     },
                     {
-                        "description": "Mandatory report information",
-                        "name": "userinfo",
+                        "description": "The sanction",
+                        "name": "sanction",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "object",
-                            "$ref": "#/definitions/actions.LightReport"
+                            "$ref": "#/definitions/models.LightSanction"
                         }
                     }
                 ],
@@ -550,7 +2365,7 @@ var doc = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.Report"
+                            "$ref": "#/definitions/models.Sanction"
                         }
                     },
                     "400": {
@@ -565,14 +2380,14 @@ var doc = `{
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
                     },
-                    "409": {
-                        "description": "You can't report yourself",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/actions.FormattedError"
                         }
@@ -605,7 +2420,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/string"
+                            "type": "string"
                         }
                     },
                     "400": {
@@ -637,6 +2452,31 @@ var doc = `{
         }
     },
     "definitions": {
+        "actions.AutoAcceptedRequest": {
+            "type": "object",
+            "properties": {
+                "auto_accepted": {
+                    "description": "Always true",
+                    "type": "boolean"
+                },
+                "friendship": {
+                    "description": "The resulting friendship",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Friendship"
+                        }
+                    ]
+                },
+                "request": {
+                    "description": "The accepted request",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.FriendRequest"
+                        }
+                    ]
+                }
+            }
+        },
         "actions.FormattedError": {
             "type": "object",
             "properties": {
@@ -648,39 +2488,122 @@ var doc = `{
                 }
             }
         },
-        "actions.LightFriendRequest": {
+        "actions.LoginInfo": {
             "type": "object",
             "properties": {
-                "message": {
+                "login": {
+                    "type": "string"
+                },
+                "password": {
                     "type": "string"
                 }
             }
         },
-        "actions.LightReport": {
+        "actions.RefreshInfo": {
             "type": "object",
             "properties": {
-                "info": {
+                "refresh_token": {
                     "type": "string"
                 }
             }
         },
-        "actions.LightUser": {
+        "actions.TokenPair": {
             "type": "object",
             "properties": {
-                "admin": {
-                    "description": "User has admin powers",
+                "access_token": {
                     "type": "string"
                 },
-                "info": {
-                    "description": "Optional user info",
+                "expires_in": {
+                    "description": "Access token lifetime (seconds)",
+                    "type": "integer"
+                },
+                "refresh_token": {
                     "type": "string"
                 },
-                "login": {
-                    "description": "User login (must be unique)",
+                "token_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuditChange": {
+            "type": "object",
+            "properties": {
+                "after": {},
+                "before": {}
+            }
+        },
+        "models.AuditChanges": {
+            "type": "object",
+            "additionalProperties": {
+                "$ref": "#/definitions/models.AuditChange"
+            }
+        },
+        "models.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "What was done",
+                    "type": "string"
+                },
+                "actor_id": {
+                    "description": "Who performed the action",
+                    "type": "string"
+                },
+                "changes": {
+                    "$ref": "#/definitions/models.AuditChanges"
+                },
+                "client_request_id": {
+                    "description": "Given by the client or a proxy in the X-Request-ID header, if any",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "description": "Generated by the server",
+                    "type": "string"
+                },
+                "target_id": {
+                    "description": "Which object it was done to, if any",
+                    "type": "string"
+                },
+                "target_type": {
+                    "description": "What kind of object it was done to",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Block": {
+            "type": "object",
+            "properties": {
+                "blocked_id": {
+                    "type": "string"
+                },
+                "blocker_id": {
+                    "type": "string"
+                },
+                "created_at": {
                     "type": "string"
                 }
             }
         },
+        "models.CategoryCount": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "count": {
+                    "type": "integer"
+                }
+            }
+        },
         "models.FriendRequest": {
             "type": "object",
             "properties": {
@@ -688,7 +2611,6 @@ var doc = `{
                     "type": "string"
                 },
                 "from": {
-                    "type": "object",
                     "$ref": "#/definitions/models.User"
                 },
                 "id": {
@@ -697,8 +2619,13 @@ var doc = `{
                 "message": {
                     "type": "string"
                 },
+                "responded_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
                 "to": {
-                    "type": "object",
                     "$ref": "#/definitions/models.User"
                 },
                 "updated_at": {
@@ -706,21 +2633,138 @@ var doc = `{
                 }
             }
         },
-        "models.FriendRequests": {
-            "type": "array",
-            "items": {}
+        "models.Friendship": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "friend_id": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.LightFriendRequest": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "description": "Optional message for the friend request",
+                    "type": "string"
+                }
+            }
+        },
+        "models.LightReport": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "description": "Defaults to \"other\"",
+                    "type": "string",
+                    "enum": [
+                        "spam",
+                        "harassment",
+                        "impersonation",
+                        "inappropriate_content",
+                        "other"
+                    ]
+                },
+                "info": {
+                    "description": "Details, mandatory for the \"other\" category",
+                    "type": "string"
+                }
+            }
+        },
+        "models.LightSanction": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "Mandatory for suspensions only",
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string",
+                    "enum": [
+                        "warning",
+                        "suspension",
+                        "ban"
+                    ]
+                },
+                "reason": {
+                    "description": "Why the user is sanctioned",
+                    "type": "string"
+                },
+                "report_id": {
+                    "description": "Optional report that triggered the sanction",
+                    "type": "string"
+                }
+            }
+        },
+        "models.LightUser": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "description": "User has admin credentials",
+                    "type": "boolean"
+                },
+                "info": {
+                    "description": "Optional user information",
+                    "type": "string"
+                },
+                "login": {
+                    "description": "Unique login",
+                    "type": "string"
+                },
+                "password": {
+                    "description": "Password (mandatory on creation)",
+                    "type": "string"
+                }
+            }
+        },
+        "models.QueueEntry": {
+            "type": "object",
+            "properties": {
+                "flagged_at": {
+                    "description": "When the user was last flagged, if ever",
+                    "type": "string"
+                },
+                "last_report_at": {
+                    "description": "Date of the latest open report",
+                    "type": "string"
+                },
+                "open_reports": {
+                    "description": "Number of open reports",
+                    "type": "integer"
+                },
+                "reporters": {
+                    "description": "Number of distinct reporters",
+                    "type": "integer"
+                },
+                "severity": {
+                    "description": "Sum of the open reports' weights",
+                    "type": "integer"
+                },
+                "user": {
+                    "$ref": "#/definitions/models.User"
+                }
+            }
         },
         "models.Report": {
             "type": "object",
             "properties": {
                 "about": {
-                    "type": "object",
                     "$ref": "#/definitions/models.User"
                 },
+                "assignee_id": {
+                    "type": "string"
+                },
                 "by": {
-                    "type": "object",
                     "$ref": "#/definitions/models.User"
                 },
+                "category": {
+                    "type": "string"
+                },
                 "created_at": {
                     "type": "string"
                 },
@@ -729,31 +2773,117 @@ var doc = `{
                 },
                 "info": {
                     "type": "string"
+                },
+                "reporter_dismissal_rate": {
+                    "description": "Fraction of the reporter's closed reports that were dismissed, only\ncomputed for moderators (absent if none of their reports was closed).",
+                    "type": "number"
+                },
+                "resolution": {
+                    "type": "string"
+                },
+                "resolved_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "models.Reports": {
-            "type": "array",
-            "items": {
-                "type": "object",
-                "properties": {
-                    "about": {
-                        "type": "object",
-                        "$ref": "#/definitions/models.User"
-                    },
-                    "by": {
-                        "type": "object",
+        "models.ReportAssignment": {
+            "type": "object",
+            "properties": {
+                "assignee_id": {
+                    "description": "Defaults to the caller",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ReportResolution": {
+            "type": "object",
+            "properties": {
+                "resolution": {
+                    "description": "Moderator's notes",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "resolved",
+                        "dismissed"
+                    ]
+                }
+            }
+        },
+        "models.Role": {
+            "type": "string",
+            "enum": [
+                "user",
+                "support",
+                "moderator",
+                "admin"
+            ],
+            "x-enum-varnames": [
+                "RoleUser",
+                "RoleSupport",
+                "RoleModerator",
+                "RoleAdmin"
+            ]
+        },
+        "models.Sanction": {
+            "type": "object",
+            "properties": {
+                "by_id": {
+                    "description": "Null once the issuer's account is deleted",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "lifted_at": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "report_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Suggestion": {
+            "type": "object",
+            "properties": {
+                "mutual_count": {
+                    "description": "Number of mutual friends",
+                    "type": "integer"
+                },
+                "mutual_friends": {
+                    "description": "A few of these mutual friends",
+                    "type": "array",
+                    "items": {
                         "$ref": "#/definitions/models.User"
-                    },
-                    "created_at": {
-                        "type": "string"
-                    },
-                    "id": {
-                        "type": "string"
-                    },
-                    "info": {
-                        "type": "string"
                     }
+                },
+                "user": {
+                    "$ref": "#/definitions/models.User"
                 }
             }
         },
@@ -767,15 +2897,19 @@ var doc = `{
                     "type": "string"
                 },
                 "friends": {
-                    "type": "object",
-                    "$ref": "#/definitions/models.Users"
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.User"
+                    }
                 },
                 "id": {
                     "type": "string"
                 },
                 "incoming_requests": {
-                    "type": "object",
-                    "$ref": "#/definitions/models.FriendRequests"
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FriendRequest"
+                    }
                 },
                 "info": {
                     "type": "string"
@@ -783,22 +2917,37 @@ var doc = `{
                 "login": {
                     "type": "string"
                 },
+                "mutual_friend_count": {
+                    "type": "integer"
+                },
                 "pending_requests": {
-                    "type": "object",
-                    "$ref": "#/definitions/models.FriendRequests"
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FriendRequest"
+                    }
                 },
                 "reports": {
-                    "type": "object",
-                    "$ref": "#/definitions/models.Reports"
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Report"
+                    }
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Role"
+                    }
+                },
+                "sanctions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Sanction"
+                    }
                 },
                 "updated_at": {
                     "type": "string"
                 }
             }
-        },
-        "models.Users": {
-            "type": "array",
-            "items": {}
         }
     },
     "securityDefinitions": {
@@ -810,49 +2959,18 @@ var doc = `{
     }
 }`
 
-type swaggerInfo struct {
-	Version     string
-	Host        string
-	BasePath    string
-	Schemes     []string
-	Title       string
-	Description string
-}
-
 // SwaggerInfo holds exported Swagger Info so clients can modify it
-var SwaggerInfo = swaggerInfo{
-	Version:     "1.0",
-	Host:        "localhost:3000",
-	BasePath:    "/",
-	Schemes:     []string{},
-	Title:       "Microsocial API",
-	Description: "toy social-network REST API",
-}
-
-type s struct{}
-
-func (s *s) ReadDoc() string {
-	sInfo := SwaggerInfo
-	sInfo.Description = strings.Replace(sInfo.Description, "\n", "\\n", -1)
-
-	t, err := template.New("swagger_info").Funcs(template.FuncMap{
-		"marshal": func(v interface{}) string {
-			a, _ := json.Marshal(v)
-			return string(a)
-		},
-	}).Parse(doc)
-	if err != nil {
-		return doc
-	}
-
-	var tpl bytes.Buffer
-	if err := t.Execute(&tpl, sInfo); err != nil {
-		return doc
-	}
-
-	return tpl.String()
+var SwaggerInfo = &swag.Spec{
+	Version:          "1.0",
+	Host:             "localhost:3000",
+	BasePath:         "/",
+	Schemes:          []string{},
+	Title:            "Microsocial API",
+	Description:      "Toy social-network REST API",
+	InfoInstanceName: "swagger",
+	SwaggerTemplate:  docTemplate,
 }
 
 func init() {
-	swag.Register(swag.Name, &s{})
+	swag.Register(SwaggerInfo.InstanceName(), SwaggerInfo)
 }
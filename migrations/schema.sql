@@ -30,10 +30,68 @@ CREATE EXTENSION IF NOT EXISTS plpgsql WITH SCHEMA pg_catalog;
 COMMENT ON EXTENSION plpgsql IS 'PL/pgSQL procedural language';
 
 
+--
+-- Name: pg_trgm; Type: EXTENSION; Schema: -; Owner: 
+--
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA public;
+
+
+--
+-- Name: EXTENSION pg_trgm; Type: COMMENT; Schema: -; Owner: 
+--
+
+COMMENT ON EXTENSION pg_trgm IS 'text similarity measurement and index searching based on trigrams';
+
+
+--
+-- Name: audit_events_append_only(); Type: FUNCTION; Schema: public; Owner: buffalo
+--
+
+CREATE FUNCTION public.audit_events_append_only() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$ BEGIN RAISE EXCEPTION 'audit events are append-only'; END; $$;
+
+
+ALTER FUNCTION public.audit_events_append_only() OWNER TO buffalo;
+
 SET default_tablespace = '';
 
 SET default_with_oids = false;
 
+--
+-- Name: audit_events; Type: TABLE; Schema: public; Owner: buffalo
+--
+
+CREATE TABLE public.audit_events (
+    id uuid NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    actor_id uuid,
+    action character varying(255) NOT NULL,
+    target_type character varying(255) NOT NULL,
+    target_id uuid,
+    changes jsonb,
+    request_id character varying(255) DEFAULT ''::character varying NOT NULL,
+    ip character varying(255) DEFAULT ''::character varying NOT NULL,
+    client_request_id character varying(255) DEFAULT ''::character varying NOT NULL
+);
+
+
+ALTER TABLE public.audit_events OWNER TO buffalo;
+
+--
+-- Name: blocks; Type: TABLE; Schema: public; Owner: buffalo
+--
+
+CREATE TABLE public.blocks (
+    created_at timestamp without time zone DEFAULT LOCALTIMESTAMP NOT NULL,
+    blocker_id uuid NOT NULL,
+    blocked_id uuid NOT NULL
+);
+
+
+ALTER TABLE public.blocks OWNER TO buffalo;
+
 --
 -- Name: friend_requests; Type: TABLE; Schema: public; Owner: buffalo
 --
@@ -45,7 +103,8 @@ CREATE TABLE public.friend_requests (
     from_id uuid NOT NULL,
     to_id uuid NOT NULL,
     message text NOT NULL,
-    status character varying(10) DEFAULT 'PENDING'::character varying NOT NULL
+    status character varying(10) DEFAULT 'PENDING'::character varying NOT NULL,
+    responded_at timestamp without time zone
 );
 
 
@@ -64,6 +123,24 @@ CREATE TABLE public.friendships (
 
 ALTER TABLE public.friendships OWNER TO buffalo;
 
+--
+-- Name: refresh_tokens; Type: TABLE; Schema: public; Owner: buffalo
+--
+
+CREATE TABLE public.refresh_tokens (
+    id uuid NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    user_id uuid NOT NULL,
+    family_id uuid NOT NULL,
+    token_hash character varying(255) NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    revoked_at timestamp without time zone
+);
+
+
+ALTER TABLE public.refresh_tokens OWNER TO buffalo;
+
 --
 -- Name: reports; Type: TABLE; Schema: public; Owner: buffalo
 --
@@ -73,12 +150,50 @@ CREATE TABLE public.reports (
     created_at timestamp without time zone NOT NULL,
     by_id uuid NOT NULL,
     about_id uuid NOT NULL,
-    info text NOT NULL
+    info text NOT NULL,
+    updated_at timestamp without time zone DEFAULT LOCALTIMESTAMP NOT NULL,
+    status character varying(255) DEFAULT 'open'::character varying NOT NULL,
+    assignee_id uuid,
+    resolution text DEFAULT ''::text NOT NULL,
+    resolved_at timestamp without time zone,
+    category character varying(255) DEFAULT 'other'::character varying NOT NULL
 );
 
 
 ALTER TABLE public.reports OWNER TO buffalo;
 
+--
+-- Name: revoked_tokens; Type: TABLE; Schema: public; Owner: buffalo
+--
+
+CREATE TABLE public.revoked_tokens (
+    jti character varying(255) NOT NULL,
+    expires_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.revoked_tokens OWNER TO buffalo;
+
+--
+-- Name: sanctions; Type: TABLE; Schema: public; Owner: buffalo
+--
+
+CREATE TABLE public.sanctions (
+    id uuid NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    user_id uuid NOT NULL,
+    by_id uuid,
+    report_id uuid,
+    kind character varying(255) NOT NULL,
+    reason text NOT NULL,
+    expires_at timestamp without time zone,
+    lifted_at timestamp without time zone
+);
+
+
+ALTER TABLE public.sanctions OWNER TO buffalo;
+
 --
 -- Name: schema_migration; Type: TABLE; Schema: public; Owner: buffalo
 --
@@ -90,6 +205,19 @@ CREATE TABLE public.schema_migration (
 
 ALTER TABLE public.schema_migration OWNER TO buffalo;
 
+--
+-- Name: user_roles; Type: TABLE; Schema: public; Owner: buffalo
+--
+
+CREATE TABLE public.user_roles (
+    created_at timestamp without time zone DEFAULT LOCALTIMESTAMP NOT NULL,
+    user_id uuid NOT NULL,
+    role character varying(20) NOT NULL
+);
+
+
+ALTER TABLE public.user_roles OWNER TO buffalo;
+
 --
 -- Name: users; Type: TABLE; Schema: public; Owner: buffalo
 --
@@ -100,12 +228,32 @@ CREATE TABLE public.users (
     updated_at timestamp without time zone NOT NULL,
     login character varying(255) NOT NULL,
     info character varying(255) NOT NULL,
-    admin boolean NOT NULL
+    admin boolean NOT NULL,
+    password_hash character varying(255) DEFAULT ''::character varying NOT NULL,
+    token_version integer DEFAULT 0 NOT NULL,
+    flagged_at timestamp without time zone,
+    lock_version integer DEFAULT 0 NOT NULL
 );
 
 
 ALTER TABLE public.users OWNER TO buffalo;
 
+--
+-- Name: audit_events audit_events_pkey; Type: CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.audit_events
+    ADD CONSTRAINT audit_events_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: blocks blocks_pkey; Type: CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.blocks
+    ADD CONSTRAINT blocks_pkey PRIMARY KEY (blocker_id, blocked_id);
+
+
 --
 -- Name: friend_requests friend_requests_pkey; Type: CONSTRAINT; Schema: public; Owner: buffalo
 --
@@ -122,6 +270,14 @@ ALTER TABLE ONLY public.friendships
     ADD CONSTRAINT friendships_pkey PRIMARY KEY (user_id, friend_id);
 
 
+--
+-- Name: refresh_tokens refresh_tokens_pkey; Type: CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.refresh_tokens
+    ADD CONSTRAINT refresh_tokens_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: reports reports_pkey; Type: CONSTRAINT; Schema: public; Owner: buffalo
 --
@@ -130,6 +286,30 @@ ALTER TABLE ONLY public.reports
     ADD CONSTRAINT reports_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: revoked_tokens revoked_tokens_pkey; Type: CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.revoked_tokens
+    ADD CONSTRAINT revoked_tokens_pkey PRIMARY KEY (jti);
+
+
+--
+-- Name: sanctions sanctions_pkey; Type: CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.sanctions
+    ADD CONSTRAINT sanctions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: user_roles user_roles_pkey; Type: CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.user_roles
+    ADD CONSTRAINT user_roles_pkey PRIMARY KEY (user_id, role);
+
+
 --
 -- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: buffalo
 --
@@ -138,6 +318,111 @@ ALTER TABLE ONLY public.users
     ADD CONSTRAINT users_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: audit_events_action_created_at_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX audit_events_action_created_at_idx ON public.audit_events USING btree (action, created_at);
+
+
+--
+-- Name: audit_events_actor_id_created_at_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX audit_events_actor_id_created_at_idx ON public.audit_events USING btree (actor_id, created_at);
+
+
+--
+-- Name: audit_events_target_type_target_id_created_at_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX audit_events_target_type_target_id_created_at_idx ON public.audit_events USING btree (target_type, target_id, created_at);
+
+
+--
+-- Name: blocks_blocked_id_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX blocks_blocked_id_idx ON public.blocks USING btree (blocked_id);
+
+
+--
+-- Name: friend_requests_from_id_status_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX friend_requests_from_id_status_idx ON public.friend_requests USING btree (from_id, status);
+
+
+--
+-- Name: friend_requests_to_id_status_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX friend_requests_to_id_status_idx ON public.friend_requests USING btree (to_id, status);
+
+
+--
+-- Name: friendships_friend_id_user_id_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX friendships_friend_id_user_id_idx ON public.friendships USING btree (friend_id, user_id);
+
+
+--
+-- Name: refresh_tokens_family_id_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX refresh_tokens_family_id_idx ON public.refresh_tokens USING btree (family_id);
+
+
+--
+-- Name: refresh_tokens_token_hash_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE UNIQUE INDEX refresh_tokens_token_hash_idx ON public.refresh_tokens USING btree (token_hash);
+
+
+--
+-- Name: reports_about_id_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX reports_about_id_idx ON public.reports USING btree (about_id);
+
+
+--
+-- Name: reports_by_id_created_at_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX reports_by_id_created_at_idx ON public.reports USING btree (by_id, created_at);
+
+
+--
+-- Name: reports_category_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX reports_category_idx ON public.reports USING btree (category);
+
+
+--
+-- Name: reports_open_by_id_about_id_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE UNIQUE INDEX reports_open_by_id_about_id_idx ON public.reports USING btree (by_id, about_id) WHERE ((status)::text = ANY ((ARRAY['open'::character varying, 'in_review'::character varying])::text[]));
+
+
+--
+-- Name: reports_status_created_at_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX reports_status_created_at_idx ON public.reports USING btree (status, created_at);
+
+
+--
+-- Name: sanctions_user_id_kind_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX sanctions_user_id_kind_idx ON public.sanctions USING btree (user_id, kind);
+
+
 --
 -- Name: schema_migration_version_idx; Type: INDEX; Schema: public; Owner: buffalo
 --
@@ -145,6 +430,43 @@ ALTER TABLE ONLY public.users
 CREATE UNIQUE INDEX schema_migration_version_idx ON public.schema_migration USING btree (version);
 
 
+--
+-- Name: users_info_tsv_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX users_info_tsv_idx ON public.users USING gin (to_tsvector('english'::regconfig, (info)::text));
+
+
+--
+-- Name: users_login_trgm_idx; Type: INDEX; Schema: public; Owner: buffalo
+--
+
+CREATE INDEX users_login_trgm_idx ON public.users USING gin (lower((login)::text) public.gin_trgm_ops);
+
+
+--
+-- Name: audit_events audit_events_append_only; Type: TRIGGER; Schema: public; Owner: buffalo
+--
+
+CREATE TRIGGER audit_events_append_only BEFORE DELETE OR UPDATE ON public.audit_events FOR EACH ROW EXECUTE PROCEDURE public.audit_events_append_only();
+
+
+--
+-- Name: blocks blocks_users_blocked_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.blocks
+    ADD CONSTRAINT blocks_users_blocked_id_fk FOREIGN KEY (blocked_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
+--
+-- Name: blocks blocks_users_blocker_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.blocks
+    ADD CONSTRAINT blocks_users_blocker_id_fk FOREIGN KEY (blocker_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
 --
 -- Name: friend_requests friend_requests_users_from_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
 --
@@ -177,6 +499,14 @@ ALTER TABLE ONLY public.friendships
     ADD CONSTRAINT friendships_users_user_id_fk FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
 
 
+--
+-- Name: refresh_tokens refresh_tokens_users_user_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.refresh_tokens
+    ADD CONSTRAINT refresh_tokens_users_user_id_fk FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
 --
 -- Name: reports reports_users_about_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
 --
@@ -185,6 +515,14 @@ ALTER TABLE ONLY public.reports
     ADD CONSTRAINT reports_users_about_id_fk FOREIGN KEY (about_id) REFERENCES public.users(id) ON DELETE CASCADE;
 
 
+--
+-- Name: reports reports_users_assignee_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.reports
+    ADD CONSTRAINT reports_users_assignee_id_fk FOREIGN KEY (assignee_id) REFERENCES public.users(id) ON DELETE SET NULL;
+
+
 --
 -- Name: reports reports_users_by_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
 --
@@ -193,6 +531,38 @@ ALTER TABLE ONLY public.reports
     ADD CONSTRAINT reports_users_by_id_fk FOREIGN KEY (by_id) REFERENCES public.users(id) ON DELETE CASCADE;
 
 
+--
+-- Name: sanctions sanctions_reports_report_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.sanctions
+    ADD CONSTRAINT sanctions_reports_report_id_fk FOREIGN KEY (report_id) REFERENCES public.reports(id) ON DELETE SET NULL;
+
+
+--
+-- Name: sanctions sanctions_users_by_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.sanctions
+    ADD CONSTRAINT sanctions_users_by_id_fk FOREIGN KEY (by_id) REFERENCES public.users(id) ON DELETE SET NULL;
+
+
+--
+-- Name: sanctions sanctions_users_user_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.sanctions
+    ADD CONSTRAINT sanctions_users_user_id_fk FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
+--
+-- Name: user_roles user_roles_users_user_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: buffalo
+--
+
+ALTER TABLE ONLY public.user_roles
+    ADD CONSTRAINT user_roles_users_user_id_fk FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
 --
 -- PostgreSQL database dump complete
 --
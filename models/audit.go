@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// Audited actions
+const (
+	AuditUserUpdate     = "user.update"      // A user's profile was edited by someone else
+	AuditUserPromote    = "user.promote"     // A user's admin flag was changed
+	AuditUserDelete     = "user.delete"      // A user was deleted by someone else
+	AuditUserGrantRole  = "user.grant_role"  // A role was granted to a user
+	AuditUserRevokeRole = "user.revoke_role" // A role was revoked from a user
+	AuditSanctionCreate = "sanction.create"  // A user was sanctioned
+	AuditSanctionLift   = "sanction.lift"    // A sanction was lifted
+	AuditReportsRead    = "reports.read"     // Reports were read by a moderator
+)
+
+// Audit target types
+const (
+	AuditTargetUser     = "user"
+	AuditTargetReport   = "report"
+	AuditTargetSanction = "sanction"
+)
+
+// AuditChange is the value of a field before and after an action
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges maps the changed fields to their values before and after an
+// action. It is stored as JSON.
+type AuditChanges map[string]AuditChange
+
+// Value implements driver.Valuer
+func (ac AuditChanges) Value() (driver.Value, error) {
+	if ac == nil {
+		return nil, nil
+	}
+	js, err := json.Marshal(ac)
+	if err != nil {
+		return nil, err
+	}
+	return string(js), nil
+}
+
+// Scan implements sql.Scanner
+func (ac *AuditChanges) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*ac = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, ac)
+	case string:
+		return json.Unmarshal([]byte(src), ac)
+	}
+	return errors.Errorf("Can't scan %T into AuditChanges", src)
+}
+
+// auditIgnored lists the fields that are left out of diffs, because they
+// change on every update.
+var auditIgnored = map[string]bool{
+	"updated_at": true,
+}
+
+// Diff compares the JSON representations of an object before and after an
+// action, and lists the fields that changed. Either side may be nil, e.g.
+// when the object was deleted.
+func Diff(before, after interface{}) (AuditChanges, error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := AuditChanges{}
+	for k, v := range b {
+		if !auditIgnored[k] && !reflect.DeepEqual(v, a[k]) {
+			changes[k] = AuditChange{Before: v, After: a[k]}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok && !auditIgnored[k] {
+			changes[k] = AuditChange{After: v}
+		}
+	}
+	return changes, nil
+}
+
+// jsonFields returns the fields of an object's JSON representation
+func jsonFields(obj interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v := reflect.ValueOf(obj); !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return fields, nil
+	}
+	js, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return fields, json.Unmarshal(js, &fields)
+}
+
+// AuditEvent model struct: a trace of a privileged action.
+//
+// Audit events are append-only: they can't be updated nor deleted, which is
+// also enforced by a trigger in the database.
+type AuditEvent struct {
+	ID         uuid.UUID    `json:"id" db:"id"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	ActorID    nulls.UUID   `json:"actor_id" db:"actor_id"`       // Who performed the action
+	Action     string       `json:"action" db:"action"`           // What was done
+	TargetType string       `json:"target_type" db:"target_type"` // What kind of object it was done to
+	TargetID   nulls.UUID   `json:"target_id" db:"target_id"`     // Which object it was done to, if any
+	Changes    AuditChanges `json:"changes,omitempty" db:"changes"`
+	RequestID  string       `json:"request_id" db:"request_id"` // Generated by the server
+	IP         string       `json:"ip" db:"ip"`
+
+	// Given by the client or a proxy in the X-Request-ID header, if any
+	ClientRequestID string `json:"client_request_id" db:"client_request_id"`
+}
+
+// String is not required by pop and may be deleted
+func (e AuditEvent) String() string {
+	js, _ := json.Marshal(e)
+	return string(js)
+}
+
+// AuditEvents is not required by pop and may be deleted
+type AuditEvents []AuditEvent
+
+// Validate an AuditEvent
+func (e *AuditEvent) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: e.Action, Name: "Action"},
+		&validators.StringIsPresent{Field: e.TargetType, Name: "TargetType"},
+	), nil
+}
+
+// Create records an audit event. There is deliberately no way to update or
+// destroy it afterwards.
+func (e *AuditEvent) Create(tx *pop.Connection) error {
+	verrs, err := tx.ValidateAndCreate(e)
+	if err != nil {
+		return err
+	}
+	if verrs.HasAny() {
+		return verrs
+	}
+	return nil
+}
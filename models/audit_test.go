@@ -0,0 +1,57 @@
+package models
+
+import "github.com/gobuffalo/nulls"
+
+func (ms *ModelSuite) Test_Diff() {
+	user := ms.createRandomUser()
+	before := *user
+	user.Info = "Changed"
+	user.Admin = true
+
+	changes, err := Diff(&before, user)
+	ms.NoError(err)
+	ms.Equal(2, len(changes))
+	ms.Equal(before.Info, changes["info"].Before)
+	ms.Equal("Changed", changes["info"].After)
+	ms.Equal(false, changes["admin"].Before)
+	ms.Equal(true, changes["admin"].After)
+
+	// Deletion
+	changes, err = Diff(user, nil)
+	ms.NoError(err)
+	ms.Equal(user.Login, changes["login"].Before)
+	ms.Nil(changes["login"].After)
+}
+
+func (ms *ModelSuite) Test_AuditEvent_AppendOnly() {
+	actor := ms.createRandomUser()
+	target := ms.createRandomUser()
+
+	event := &AuditEvent{
+		ActorID:    nulls.NewUUID(actor.ID),
+		Action:     AuditUserUpdate,
+		TargetType: AuditTargetUser,
+		TargetID:   nulls.NewUUID(target.ID),
+		Changes:    AuditChanges{"info": {Before: "old", After: "new"}},
+	}
+	ms.NoError(event.Create(ms.DB))
+	ms.NotZero(event.ID)
+
+	saved := &AuditEvent{}
+	ms.NoError(ms.DB.Find(saved, event.ID))
+	ms.Equal(event.Changes, saved.Changes)
+
+	// Events can't be altered nor deleted
+	saved.Action = AuditUserDelete
+	ms.Error(ms.DB.Update(saved))
+	ms.Error(ms.DB.Destroy(saved))
+
+	// ... and survive the users they refer to
+	ms.NoError(ms.DB.Destroy(actor))
+	count, err := ms.DB.Where("actor_id = ?", actor.ID).Count("audit_events")
+	ms.NoError(err)
+	ms.Equal(1, count)
+
+	// Events need an action and a target type
+	ms.Error((&AuditEvent{}).Create(ms.DB))
+}
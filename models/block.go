@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// ErrSelfBlock is returned when a user tries to block themselves
+var ErrSelfBlock = errors.New("Can't block yourself")
+
+// Block model struct: the "blocker" doesn't want to hear from the "blocked"
+// user anymore.
+type Block struct {
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	BlockerID uuid.UUID `json:"blocker_id" db:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id" db:"blocked_id"`
+}
+
+// Create blocks a user. Any friendship or pending friend request between
+// both users is destroyed (resp. cancelled) along the way.
+func (b *Block) Create(tx *pop.Connection) error {
+	if b.BlockerID == b.BlockedID {
+		return ErrSelfBlock
+	}
+
+	fs := &Friendship{
+		UserID:   b.BlockerID,
+		FriendID: b.BlockedID,
+	}
+	if err := fs.Destroy(tx); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err := tx.RawQuery(
+		`UPDATE friend_requests SET status = ?, responded_at = ?, updated_at = ?
+		WHERE
+			status = ?
+			AND (
+				(from_id = ? AND to_id = ?)
+				OR
+				(from_id = ? AND to_id = ?)
+			)`,
+		RequestCancelled, now, now, RequestPending,
+		b.BlockerID, b.BlockedID, b.BlockedID, b.BlockerID,
+	).Exec()
+	if err != nil {
+		return err
+	}
+
+	b.CreatedAt = now
+	return tx.RawQuery(
+		`INSERT INTO blocks (created_at, blocker_id, blocked_id) VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		b.CreatedAt, b.BlockerID, b.BlockedID,
+	).Exec()
+}
+
+// Destroy unblocks a user
+func (b *Block) Destroy(tx *pop.Connection) error {
+	return tx.RawQuery(
+		"DELETE FROM blocks WHERE blocker_id = ? AND blocked_id = ?",
+		b.BlockerID, b.BlockedID,
+	).Exec()
+}
+
+// IsBlocked tells whether a user has blocked another
+func IsBlocked(tx *pop.Connection, blockerID, blockedID uuid.UUID) (bool, error) {
+	return tx.Where(
+		"blocker_id = ? AND blocked_id = ?", blockerID, blockedID,
+	).Exists("blocks")
+}
+
+// AreBlocked tells whether any of two users has blocked the other
+func AreBlocked(tx *pop.Connection, userID, otherID uuid.UUID) (bool, error) {
+	return tx.Where(
+		"(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)",
+		userID, otherID, otherID, userID,
+	).Exists("blocks")
+}
+
+// BlockedWith lists the users who blocked given user or were blocked by them
+func BlockedWith(tx *pop.Connection, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows := []idRow{}
+	err := tx.RawQuery(
+		`SELECT blocked_id AS id FROM blocks WHERE blocker_id = ?
+		UNION
+		SELECT blocker_id AS id FROM blocks WHERE blocked_id = ?`,
+		userID, userID,
+	).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	return ids, nil
+}
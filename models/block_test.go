@@ -0,0 +1,58 @@
+package models
+
+func (ms *ModelSuite) Test_Block_Create() {
+	user := ms.createRandomUser()
+	friend := ms.createRandomUser()
+	other := ms.createRandomUser()
+
+	req, err := user.SendRequest(ms.DB, friend, "")
+	ms.NoError(err)
+	ms.NoError(req.Accept(ms.DB))
+	pending, err := other.SendRequest(ms.DB, user, "")
+	ms.NoError(err)
+
+	// Can't block yourself
+	block := &Block{BlockerID: user.ID, BlockedID: user.ID}
+	ms.Equal(ErrSelfBlock, block.Create(ms.DB))
+
+	// Blocking a friend destroys the friendship
+	block = &Block{BlockerID: user.ID, BlockedID: friend.ID}
+	ms.NoError(block.Create(ms.DB))
+	count, err := ms.DB.Count("friendships")
+	ms.NoError(err)
+	ms.Equal(0, count)
+
+	// Blocking twice is harmless
+	ms.NoError(block.Create(ms.DB))
+
+	// Blocking cancels pending requests
+	block = &Block{BlockerID: user.ID, BlockedID: other.ID}
+	ms.NoError(block.Create(ms.DB))
+	err = ms.DB.Reload(pending)
+	ms.NoError(err)
+	ms.Equal(RequestCancelled, pending.Status)
+
+	blocked, err := IsBlocked(ms.DB, user.ID, other.ID)
+	ms.NoError(err)
+	ms.True(blocked)
+	blocked, err = IsBlocked(ms.DB, other.ID, user.ID)
+	ms.NoError(err)
+	ms.False(blocked)
+	blocked, err = AreBlocked(ms.DB, other.ID, user.ID)
+	ms.NoError(err)
+	ms.True(blocked)
+
+	// Blocked users can't request each other as friends, in either direction
+	_, err = other.SendRequest(ms.DB, user, "")
+	ms.Error(err)
+	_, err = user.SendRequest(ms.DB, other, "")
+	ms.Error(err)
+
+	// Unblock
+	ms.NoError(block.Destroy(ms.DB))
+	blocked, err = AreBlocked(ms.DB, other.ID, user.ID)
+	ms.NoError(err)
+	ms.False(blocked)
+	_, err = other.SendRequest(ms.DB, user, "")
+	ms.NoError(err)
+}
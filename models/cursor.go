@@ -0,0 +1,112 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// Cursor is the position of a row in a list sorted by creation date and ID.
+// Clients get it as an opaque string.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// String encodes the cursor
+func (c Cursor) String() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// ParseCursor decodes a cursor
+func ParseCursor(s string) (*Cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &Cursor{}
+	if err := json.Unmarshal(js, c); err != nil || c.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Page is a keyset pagination request: it selects at most Limit rows right
+// after, or right before, a given cursor. Unlike offset pagination, pages
+// don't drift when rows are inserted or deleted in the meantime.
+type Page struct {
+	After  *Cursor
+	Before *Cursor
+	Limit  int
+}
+
+// Apply narrows given query down to the requested page of a list sorted by
+// given date and ID columns, in ascending order (or descending order if
+// desc is true). One extra row is selected, to tell whether there are more.
+func (p *Page) Apply(q *pop.Query, timeCol, idCol string, desc bool) *pop.Query {
+	// Rows before the cursor are selected by walking the list backwards
+	backwards := p.Before != nil
+	cursor, op, dir := p.After, ">", "ASC"
+	if backwards {
+		cursor = p.Before
+	}
+	if desc != backwards {
+		op, dir = "<", "DESC"
+	}
+
+	if cursor != nil {
+		q = q.Where(
+			fmt.Sprintf("(%s, %s) %s (?, ?)", timeCol, idCol, op),
+			cursor.CreatedAt, cursor.ID,
+		)
+	}
+	return q.Order(fmt.Sprintf("%s %s, %s %s", timeCol, dir, idCol, dir)).Limit(p.Limit + 1)
+}
+
+// Trim removes the extra row selected by Apply from rows (a pointer to a
+// slice), puts them back in the list's order, and returns the cursors of the
+// previous and next pages, if any. cursor gives the position of the i-th
+// row.
+func (p *Page) Trim(rows interface{}, cursor func(i int) Cursor) (prev, next *Cursor) {
+	v := reflect.ValueOf(rows).Elem()
+	more := v.Len() > p.Limit
+	if more {
+		v.Set(v.Slice(0, p.Limit))
+	}
+
+	n := v.Len()
+	if p.Before != nil {
+		swap := reflect.Swapper(v.Interface())
+		for i := 0; i < n/2; i++ {
+			swap(i, n-1-i)
+		}
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	first, last := cursor(0), cursor(n-1)
+	if p.Before != nil {
+		if more {
+			prev = &first
+		}
+		return prev, &last
+	}
+	if p.After != nil {
+		prev = &first
+	}
+	if more {
+		next = &last
+	}
+	return prev, next
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func (ms *ModelSuite) Test_Cursor() {
+	cursor := Cursor{
+		CreatedAt: time.Date(2019, 10, 14, 12, 0, 0, 123456000, time.UTC),
+		ID:        uuid.Must(uuid.NewV4()),
+	}
+	parsed, err := ParseCursor(cursor.String())
+	ms.NoError(err)
+	ms.True(cursor.CreatedAt.Equal(parsed.CreatedAt))
+	ms.Equal(cursor.ID, parsed.ID)
+
+	for _, s := range []string{"", "not a cursor!", "e30"} {
+		_, err := ParseCursor(s)
+		ms.Equalf(ErrInvalidCursor, err, s)
+	}
+}
+
+func (ms *ModelSuite) Test_User_FetchFriendsPage() {
+	user := ms.createRandomUser()
+	friends := Users{}
+	for i := 0; i < 5; i++ {
+		friend := ms.createRandomUser()
+		ms.makeFriends(user, friend)
+		friends = append(Users{*friend}, friends...) // Most recent first
+	}
+
+	// Walk forwards
+	page := &Page{Limit: 2}
+	prev, next, err := user.FetchFriendsPage(ms.DB, page)
+	ms.NoError(err)
+	ms.Nil(prev)
+	ms.NotNil(next)
+	ms.Equal(2, len(user.Friends))
+	ms.Equal(friends[0].ID, user.Friends[0].ID)
+	ms.Equal(friends[1].ID, user.Friends[1].ID)
+
+	// A new friend doesn't shift the next pages
+	ms.makeFriends(user, ms.createRandomUser())
+
+	page = &Page{After: next, Limit: 2}
+	prev, next, err = user.FetchFriendsPage(ms.DB, page)
+	ms.NoError(err)
+	ms.NotNil(prev)
+	ms.NotNil(next)
+	ms.Equal(friends[2].ID, user.Friends[0].ID)
+	ms.Equal(friends[3].ID, user.Friends[1].ID)
+
+	page = &Page{After: next, Limit: 2}
+	prev, next, err = user.FetchFriendsPage(ms.DB, page)
+	ms.NoError(err)
+	ms.NotNil(prev)
+	ms.Nil(next, "Last page")
+	ms.Equal(1, len(user.Friends))
+	ms.Equal(friends[4].ID, user.Friends[0].ID)
+
+	// Walk backwards
+	page = &Page{Before: prev, Limit: 2}
+	prev, next, err = user.FetchFriendsPage(ms.DB, page)
+	ms.NoError(err)
+	ms.NotNil(prev)
+	ms.NotNil(next)
+	ms.Equal(friends[2].ID, user.Friends[0].ID)
+	ms.Equal(friends[3].ID, user.Friends[1].ID)
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+)
+
+// EscalationThreshold is the number of distinct users who must report
+// somebody within the escalation window for them to get flagged, read from
+// the ESCALATION_REPORT_THRESHOLD environment variable (default: 3).
+func EscalationThreshold() int {
+	return IntFromEnv("ESCALATION_REPORT_THRESHOLD", 3)
+}
+
+// EscalationWindow is the period over which reports are counted, read from
+// the ESCALATION_WINDOW environment variable (default: 24h).
+func EscalationWindow() time.Duration {
+	return DurationFromEnv("ESCALATION_WINDOW", 24*time.Hour)
+}
+
+// EscalationRestriction is how long flagged users can't send friend
+// requests, read from the ESCALATION_RESTRICTION environment variable
+// (default: 72h).
+func EscalationRestriction() time.Duration {
+	return DurationFromEnv("ESCALATION_RESTRICTION", 72*time.Hour)
+}
+
+// CategorySeverity weighs the reports of each category in the moderation
+// queue.
+var CategorySeverity = map[string]int{
+	CategoryHarassment:           5,
+	CategoryImpersonation:        4,
+	CategoryInappropriateContent: 3,
+	CategorySpam:                 2,
+	CategoryOther:                1,
+}
+
+// escalate flags a user once they've been reported by enough distinct users
+// within the escalation window. Users who are already flagged (and still
+// restricted) are left alone.
+func escalate(tx *pop.Connection, userID uuid.UUID) error {
+	now := time.Now()
+	count := &rowCount{}
+	err := tx.RawQuery(
+		`SELECT COUNT(DISTINCT by_id) AS count FROM reports
+		WHERE about_id = ? AND created_at > ?`,
+		userID, now.Add(-EscalationWindow()),
+	).First(count)
+	if err != nil {
+		return err
+	}
+	if count.Count < EscalationThreshold() {
+		return nil
+	}
+	return tx.RawQuery(
+		`UPDATE users SET flagged_at = ?
+		WHERE id = ? AND (flagged_at IS NULL OR flagged_at <= ?)`,
+		now, userID, now.Add(-EscalationRestriction()),
+	).Exec()
+}
+
+// IsRestricted tells whether a user was flagged recently enough to be
+// prevented from sending friend requests.
+func IsRestricted(tx *pop.Connection, userID uuid.UUID) (bool, error) {
+	return tx.Where(
+		"id = ? AND flagged_at > ?", userID, time.Now().Add(-EscalationRestriction()),
+	).Exists("users")
+}
+
+// QueueEntry is a reported user waiting for moderation
+type QueueEntry struct {
+	User         *User      `json:"user"`
+	FlaggedAt    nulls.Time `json:"flagged_at"`     // When the user was last flagged, if ever
+	Severity     int        `json:"severity"`       // Sum of the open reports' weights
+	OpenReports  int        `json:"open_reports"`   // Number of open reports
+	Reporters    int        `json:"reporters"`      // Number of distinct reporters
+	LastReportAt time.Time  `json:"last_report_at"` // Date of the latest open report
+}
+
+// Queue is the list of reported users waiting for moderation
+type Queue []QueueEntry
+
+// queueRow is used to scan the moderation queue
+type queueRow struct {
+	UserID       uuid.UUID  `db:"user_id"`
+	FlaggedAt    nulls.Time `db:"flagged_at"`
+	Severity     int        `db:"severity"`
+	OpenReports  int        `db:"open_reports"`
+	Reporters    int        `db:"reporters"`
+	LastReportAt time.Time  `db:"last_report_at"`
+}
+
+// FetchQueue lists the users with open reports, flagged users first, then
+// by decreasing severity. The given query is used for pagination.
+func FetchQueue(q *pop.Query) (Queue, error) {
+	severity := &bytes.Buffer{}
+	args := []interface{}{}
+	severity.WriteString("CASE reports.category")
+	for _, category := range ReportCategories {
+		severity.WriteString(" WHEN ? THEN ?")
+		args = append(args, category, CategorySeverity[category])
+	}
+	severity.WriteString(" ELSE 1 END")
+	args = append(args, ReportOpen, ReportInReview)
+
+	rows := []queueRow{}
+	err := q.RawQuery(
+		`SELECT
+			reports.about_id AS user_id,
+			users.flagged_at AS flagged_at,
+			SUM(`+severity.String()+`) AS severity,
+			COUNT(*) AS open_reports,
+			COUNT(DISTINCT reports.by_id) AS reporters,
+			MAX(reports.created_at) AS last_report_at
+		FROM reports
+		INNER JOIN users ON users.id = reports.about_id
+		WHERE reports.status IN (?, ?)
+		GROUP BY reports.about_id, users.flagged_at
+		ORDER BY
+			users.flagged_at IS NULL,
+			severity DESC,
+			last_report_at DESC,
+			reports.about_id`,
+		args...,
+	).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := make(Queue, len(rows))
+	if len(rows) == 0 {
+		return queue, nil
+	}
+
+	ids := make([]interface{}, len(rows))
+	for i, row := range rows {
+		ids[i] = row.UserID
+	}
+	users := Users{}
+	if err := q.Connection.Where("id IN (?)", ids...).All(&users); err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+
+	for i, row := range rows {
+		queue[i] = QueueEntry{
+			User:         byID[row.UserID],
+			FlaggedAt:    row.FlaggedAt,
+			Severity:     row.Severity,
+			OpenReports:  row.OpenReports,
+			Reporters:    row.Reporters,
+			LastReportAt: row.LastReportAt,
+		}
+	}
+	return queue, nil
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/validate"
+)
+
+func (ms *ModelSuite) Test_Escalation() {
+	envy.Set("ESCALATION_REPORT_THRESHOLD", "2")
+	defer envy.Set("ESCALATION_REPORT_THRESHOLD", "")
+
+	user := ms.createRandomUser()
+	other := ms.createRandomUser()
+
+	report := &Report{ByID: ms.createRandomUser().ID, AboutID: user.ID, Category: CategorySpam}
+	verrs, err := report.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+
+	restricted, err := IsRestricted(ms.DB, user.ID)
+	ms.NoError(err)
+	ms.False(restricted, "A single reporter isn't enough")
+
+	report = &Report{ByID: ms.createRandomUser().ID, AboutID: user.ID, Category: CategoryHarassment}
+	verrs, err = report.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+
+	restricted, err = IsRestricted(ms.DB, user.ID)
+	ms.NoError(err)
+	ms.True(restricted)
+
+	ms.NoError(ms.DB.Reload(user))
+	ms.True(user.FlaggedAt.Valid)
+
+	// Flagged users can't send friend requests
+	_, err = user.SendRequest(ms.DB, other, "Hi!")
+	ms.Error(err)
+	verrs, ok := err.(*validate.Errors)
+	ms.True(ok)
+	ms.NotEmpty(verrs.Get(FriendRequestRestrictedKey))
+
+	// ... but they can still be requested
+	_, err = other.SendRequest(ms.DB, user, "Hi!")
+	ms.NoError(err)
+}
+
+func (ms *ModelSuite) Test_FetchQueue() {
+	envy.Set("ESCALATION_REPORT_THRESHOLD", "3")
+	defer envy.Set("ESCALATION_REPORT_THRESHOLD", "")
+
+	flagged := ms.createRandomUser()
+	harassing := ms.createRandomUser()
+	spamming := ms.createRandomUser()
+
+	for _, r := range []*Report{
+		{ByID: ms.createRandomUser().ID, AboutID: spamming.ID, Category: CategorySpam},
+		{ByID: ms.createRandomUser().ID, AboutID: harassing.ID, Category: CategoryHarassment},
+		{ByID: ms.createRandomUser().ID, AboutID: flagged.ID, Category: CategoryOther},
+		{ByID: ms.createRandomUser().ID, AboutID: flagged.ID, Category: CategoryOther},
+		{ByID: ms.createRandomUser().ID, AboutID: flagged.ID, Category: CategoryOther},
+	} {
+		verrs, err := r.Create(ms.DB)
+		ms.NoError(err)
+		ms.Falsef(verrs.HasAny(), verrs.String())
+	}
+
+	// Closed reports don't count
+	closed := &Report{ByID: ms.createRandomUser().ID, AboutID: spamming.ID, Category: CategoryHarassment}
+	verrs, err := closed.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+	ms.NoError(closed.Resolve(ms.DB, ReportDismissed, ""))
+
+	queue, err := FetchQueue(ms.DB.Q())
+	ms.NoError(err)
+	ms.Equal(3, len(queue))
+
+	ms.Equal(flagged.ID, queue[0].User.ID)
+	ms.True(queue[0].FlaggedAt.Valid)
+	ms.Equal(3, queue[0].Severity)
+	ms.Equal(3, queue[0].OpenReports)
+	ms.Equal(3, queue[0].Reporters)
+
+	ms.Equal(harassing.ID, queue[1].User.ID)
+	ms.False(queue[1].FlaggedAt.Valid)
+	ms.Equal(CategorySeverity[CategoryHarassment], queue[1].Severity)
+
+	ms.Equal(spamming.ID, queue[2].User.ID)
+	ms.Equal(CategorySeverity[CategorySpam], queue[2].Severity)
+	ms.Equal(1, queue[2].OpenReports)
+}
@@ -5,12 +5,30 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop"
 	"github.com/gobuffalo/validate"
 	"github.com/gobuffalo/validate/validators"
 	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
 )
 
+// Friend request statuses
+const (
+	RequestPending   = "PENDING"
+	RequestAccepted  = "ACCEPTED"
+	RequestDeclined  = "DECLINED"
+	RequestCancelled = "CANCELLED"
+)
+
+// FriendRequestRestrictedKey is the validation error key used when the
+// sender is temporarily restricted after being reported too often.
+const FriendRequestRestrictedKey = "restricted"
+
+// ErrRequestNotPending is returned when trying to accept, decline or cancel
+// a friend request that was already resolved.
+var ErrRequestNotPending = errors.New("This friend request isn't pending anymore")
+
 // "Light" FriendRequest model used for friend request creation
 type LightFriendRequest struct {
 	Message string `json:"message"` // Optional message for the friend request
@@ -18,20 +36,24 @@ type LightFriendRequest struct {
 
 // FriendRequest model struct
 type FriendRequest struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
-	FromID    uuid.UUID `json:"-" db:"from_id"`
-	ToID      uuid.UUID `json:"-" db:"to_id"`
-	From      *User     `json:"from,omitempty" belongs_to:"user" `
-	To        *User     `json:"to,omitempty" belongs_to:"user"`
-	Message   string    `json:"message" db:"message"`
+	ID          uuid.UUID  `json:"id" db:"id"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	FromID      uuid.UUID  `json:"-" db:"from_id"`
+	ToID        uuid.UUID  `json:"-" db:"to_id"`
+	From        *User      `json:"from,omitempty" belongs_to:"user" `
+	To          *User      `json:"to,omitempty" belongs_to:"user"`
+	Message     string     `json:"message" db:"message"`
+	Status      string     `json:"status" db:"status"`
+	RespondedAt nulls.Time `json:"responded_at" db:"responded_at"`
 }
 
+// Friendship model struct.
+// Each friendship is stored as two symmetric rows.
 type Friendship struct {
-	CreatedAt time.Time `db:"created_at"`
-	UserID    uuid.UUID `db:"user_id"`
-	FriendID  uuid.UUID `db:"friend_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	FriendID  uuid.UUID `json:"friend_id" db:"friend_id"`
 }
 
 // FriendRequestFromLight Creates a new friend request from its "light" version
@@ -71,8 +93,27 @@ func (f *FriendRequest) ValidateCreate(tx *pop.Connection) (*validate.Errors, er
 	if verrs.HasAny() {
 		return verrs, nil
 	}
+	restricted, err := IsRestricted(tx, f.FromID)
+	if err != nil {
+		return verrs, err
+	}
+	if restricted {
+		verrs.Add(
+			FriendRequestRestrictedKey,
+			"This account can't send friend requests for now, as it was reported too often",
+		)
+		return verrs, nil
+	}
+	blocked, err := AreBlocked(tx, f.FromID, f.ToID)
+	if err != nil {
+		return verrs, err
+	}
+	if blocked {
+		verrs.Add("from_id/to_id", "Can't request friendship between blocked users")
+		return verrs, nil
+	}
 	fs := []Friendship{}
-	err := tx.Where("(user_id = ? AND friend_id = ?)", f.FromID, f.ToID).All(&fs)
+	err = tx.Where("(user_id = ? AND friend_id = ?)", f.FromID, f.ToID).All(&fs)
 	if err != nil {
 		return verrs, err
 	}
@@ -87,8 +128,10 @@ func (f *FriendRequest) ValidateCreate(tx *pop.Connection) (*validate.Errors, er
 		return verrs, nil
 	}
 	fr := []FriendRequest{}
-	err = tx.Where("(from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?)",
-		f.FromID, f.ToID, f.ToID, f.FromID).All(&fr)
+	err = tx.Where("status = ?", RequestPending).Where(
+		"(from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?)",
+		f.FromID, f.ToID, f.ToID, f.FromID,
+	).All(&fr)
 	if err != nil {
 		return verrs, err
 	}
@@ -105,12 +148,50 @@ func (f *FriendRequest) ValidateCreate(tx *pop.Connection) (*validate.Errors, er
 	return verrs, err
 }
 
+// FindPendingRequest looks up the pending friend request from a user to
+// another, if any. It returns nil if there's none.
+func FindPendingRequest(tx *pop.Connection, fromID, toID uuid.UUID) (*FriendRequest, error) {
+	fr := []FriendRequest{}
+	err := tx.Where(
+		"from_id = ? AND to_id = ? AND status = ?", fromID, toID, RequestPending,
+	).All(&fr)
+	if err != nil || len(fr) == 0 {
+		return nil, err
+	}
+	return &fr[0], nil
+}
+
+// Create saves a new pending friend request into the database
 func (f *FriendRequest) Create(tx *pop.Connection) (*validate.Errors, error) {
+	f.Status = RequestPending
 	return tx.ValidateAndCreate(f)
 }
 
+// resolve moves a pending friend request to given status.
+// The status is checked by the query itself, so that two concurrent
+// answers to the same request can't both succeed.
+func (f *FriendRequest) resolve(tx *pop.Connection, status string) error {
+	now := time.Now()
+	count, err := tx.RawQuery(
+		`UPDATE friend_requests SET status = ?, responded_at = ?, updated_at = ?
+		WHERE id = ? AND status = ?`,
+		status, now, now, f.ID, RequestPending,
+	).ExecWithCount()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrRequestNotPending
+	}
+	f.Status = status
+	f.RespondedAt = nulls.NewTime(now)
+	f.UpdatedAt = now
+	return nil
+}
+
+// Accept accepts a pending friend request, and creates the friendship
 func (f *FriendRequest) Accept(tx *pop.Connection) error {
-	if err := tx.Destroy(f); err != nil {
+	if err := f.resolve(tx, RequestAccepted); err != nil {
 		return err
 	}
 	fs := &Friendship{
@@ -120,8 +201,25 @@ func (f *FriendRequest) Accept(tx *pop.Connection) error {
 	return fs.Create(tx)
 }
 
+// Decline declines a pending friend request
 func (f *FriendRequest) Decline(tx *pop.Connection) error {
-	return tx.Destroy(f)
+	return f.resolve(tx, RequestDeclined)
+}
+
+// Cancel cancels a pending friend request
+func (f *FriendRequest) Cancel(tx *pop.Connection) error {
+	return f.resolve(tx, RequestCancelled)
+}
+
+// FindFriendship looks up the friendship between two users, if any.
+// It returns nil if they aren't friends.
+func FindFriendship(tx *pop.Connection, userID, friendID uuid.UUID) (*Friendship, error) {
+	fs := []Friendship{}
+	err := tx.Where("user_id = ? AND friend_id = ?", userID, friendID).All(&fs)
+	if err != nil || len(fs) == 0 {
+		return nil, err
+	}
+	return &fs[0], nil
 }
 
 // Create friendship
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"github.com/gobuffalo/envy"
+	"github.com/gofrs/uuid"
+)
+
 func (ms *ModelSuite) Test_FriendRequest_Create() {
 	count, err := ms.DB.Count("friend_requests")
 	ms.NoError(err)
@@ -18,6 +23,7 @@ func (ms *ModelSuite) Test_FriendRequest_Create() {
 	ms.NoError(err)
 	ms.Falsef(verrs.HasAny(), verrs.String())
 	ms.NotZero(request.ID)
+	ms.Equal(RequestPending, request.Status)
 
 	count, err = ms.DB.Count("friend_requests")
 	ms.NoError(err)
@@ -51,11 +57,22 @@ func (ms *ModelSuite) Test_FriendRequest_Accept() {
 
 	err = request.Accept(ms.DB)
 	ms.NoError(err)
+	ms.Equal(RequestAccepted, request.Status)
+	ms.True(request.RespondedAt.Valid)
 
 	count, err := ms.DB.Count("friendships")
 	ms.NoError(err)
 	ms.Equal(2, count)
 
+	// The request is kept on record, but isn't pending anymore
+	count, err = ms.DB.Count("friend_requests")
+	ms.NoError(err)
+	ms.Equal(1, count)
+
+	err = user.FetchOutRequests(ms.DB)
+	ms.NoError(err)
+	ms.Empty(user.OutRequests)
+
 	err = user.FetchFriends(ms.DB)
 	ms.NoError(err)
 	ms.Equal(1, len(user.Friends))
@@ -80,6 +97,38 @@ func (ms *ModelSuite) Test_FriendRequest_Decline() {
 	count, err := ms.DB.Count("friendships")
 	ms.NoError(err)
 	ms.Equal(0, count)
+
+	stored := &FriendRequest{}
+	err = ms.DB.Find(stored, request.ID)
+	ms.NoError(err)
+	ms.Equal(RequestDeclined, stored.Status)
+	ms.True(stored.RespondedAt.Valid)
+}
+
+func (ms *ModelSuite) Test_FriendRequest_AlreadyResolved() {
+	user := ms.createRandomUser()
+	other := ms.createRandomUser()
+
+	request, err := user.SendRequest(ms.DB, other, "")
+	ms.NoError(err)
+
+	err = request.Cancel(ms.DB)
+	ms.NoError(err)
+	ms.Equal(RequestCancelled, request.Status)
+
+	// A stale copy of the request can't be answered either
+	stale := &FriendRequest{}
+	err = ms.DB.Find(stale, request.ID)
+	ms.NoError(err)
+	stale.Status = RequestPending
+
+	ms.Equal(ErrRequestNotPending, stale.Accept(ms.DB))
+	ms.Equal(ErrRequestNotPending, stale.Decline(ms.DB))
+	ms.Equal(ErrRequestNotPending, request.Cancel(ms.DB))
+
+	count, err := ms.DB.Count("friendships")
+	ms.NoError(err)
+	ms.Equal(0, count)
 }
 
 func (ms *ModelSuite) Test_FriendRequest_Validate() {
@@ -130,3 +179,159 @@ func (ms *ModelSuite) Test_FriendRequest_Validate() {
 	ms.NoError(err)
 	ms.Truef(verrs.HasAny(), "Created friend request to a friend.")
 }
+
+func (ms *ModelSuite) Test_FindPendingRequest() {
+	user := ms.createRandomUser()
+	other := ms.createRandomUser()
+
+	req, err := FindPendingRequest(ms.DB, user.ID, other.ID)
+	ms.NoError(err)
+	ms.Nil(req)
+
+	sent, err := user.SendRequest(ms.DB, other, "")
+	ms.NoError(err)
+
+	req, err = FindPendingRequest(ms.DB, user.ID, other.ID)
+	ms.NoError(err)
+	ms.NotNil(req)
+	ms.Equal(sent.ID, req.ID)
+
+	// Direction matters
+	req, err = FindPendingRequest(ms.DB, other.ID, user.ID)
+	ms.NoError(err)
+	ms.Nil(req)
+
+	ms.NoError(sent.Accept(ms.DB))
+	req, err = FindPendingRequest(ms.DB, user.ID, other.ID)
+	ms.NoError(err)
+	ms.Nil(req)
+
+	fs, err := FindFriendship(ms.DB, other.ID, user.ID)
+	ms.NoError(err)
+	ms.NotNil(fs)
+	ms.False(fs.CreatedAt.IsZero())
+}
+
+func (ms *ModelSuite) makeFriends(user, other *User) {
+	req, err := user.SendRequest(ms.DB, other, "")
+	ms.NoError(err)
+	ms.NoError(req.Accept(ms.DB))
+}
+
+func (ms *ModelSuite) Test_User_MutualFriends() {
+	alice := ms.createRandomUser()
+	bob := ms.createRandomUser()
+	carol := ms.createRandomUser()
+	dave := ms.createRandomUser()
+	eve := ms.createRandomUser()
+
+	// Carol and Dave are friends with both Alice and Bob,
+	// Eve is only friends with Alice.
+	ms.makeFriends(alice, carol)
+	ms.makeFriends(alice, dave)
+	ms.makeFriends(alice, eve)
+	ms.makeFriends(carol, bob)
+	ms.makeFriends(dave, bob)
+	ms.makeFriends(alice, bob)
+
+	users := Users{}
+	err := MutualFriendsQuery(ms.DB.Q(), alice.ID, bob.ID).All(&users)
+	ms.NoError(err)
+	ms.Equal(2, len(users))
+	for _, u := range users {
+		ms.Contains([]string{carol.Login, dave.Login}, u.Login)
+	}
+
+	err = bob.FetchMutualCount(ms.DB, alice.ID)
+	ms.NoError(err)
+	ms.Equal(2, *bob.MutualCount)
+
+	err = eve.FetchMutualCount(ms.DB, bob.ID)
+	ms.NoError(err)
+	ms.Equal(1, *eve.MutualCount, "Alice is their only mutual friend")
+}
+
+func (ms *ModelSuite) Test_User_FetchSuggestions() {
+	alice := ms.createRandomUser()
+	bob := ms.createRandomUser()
+	carol := ms.createRandomUser()
+	dave := ms.createRandomUser()
+	eve := ms.createRandomUser()
+	frank := ms.createRandomUser()
+
+	// Bob and Carol are Alice's friends.
+	// Dave is friends with both, Eve with Bob only,
+	// Frank with Carol but he has a pending request to Alice.
+	ms.makeFriends(alice, bob)
+	ms.makeFriends(alice, carol)
+	ms.makeFriends(dave, bob)
+	ms.makeFriends(dave, carol)
+	ms.makeFriends(eve, bob)
+	ms.makeFriends(frank, carol)
+	_, err := frank.SendRequest(ms.DB, alice, "")
+	ms.NoError(err)
+
+	suggestions, err := alice.FetchSuggestions(ms.DB.Q())
+	ms.NoError(err)
+	ms.Equal(2, len(suggestions))
+
+	ms.Equal(dave.ID, suggestions[0].User.ID)
+	ms.Equal(2, suggestions[0].MutualCount)
+	ms.Equal(2, len(suggestions[0].MutualFriends))
+
+	ms.Equal(eve.ID, suggestions[1].User.ID)
+	ms.Equal(1, suggestions[1].MutualCount)
+	ms.Equal(bob.ID, suggestions[1].MutualFriends[0].ID)
+
+	// Blocked users are never suggested
+	block := &Block{BlockerID: dave.ID, BlockedID: alice.ID}
+	ms.NoError(block.Create(ms.DB))
+
+	suggestions, err = alice.FetchSuggestions(ms.DB.Q())
+	ms.NoError(err)
+	ms.Equal(1, len(suggestions))
+	ms.Equal(eve.ID, suggestions[0].User.ID)
+}
+
+func (ms *ModelSuite) Test_FindFriendPath() {
+	users := make([]*User, 6)
+	for i := range users {
+		users[i] = ms.createRandomUser()
+	}
+
+	// 0 - 1 - 2 - 3 - 4, and a shortcut 1 - 5 - 4 ; nobody knows 5 and 3
+	ms.makeFriends(users[0], users[1])
+	ms.makeFriends(users[1], users[2])
+	ms.makeFriends(users[2], users[3])
+	ms.makeFriends(users[3], users[4])
+	ms.makeFriends(users[1], users[5])
+	ms.makeFriends(users[5], users[4])
+
+	path, err := FindFriendPath(ms.DB, users[0].ID, users[4].ID, 6, nil)
+	ms.NoError(err)
+	ms.Equal(4, len(path))
+	for i, u := range []*User{users[0], users[1], users[5], users[4]} {
+		ms.Equal(u.ID, path[i].ID)
+	}
+
+	// Going around user 5
+	path, err = FindFriendPath(ms.DB, users[0].ID, users[4].ID, 6, []uuid.UUID{users[5].ID})
+	ms.NoError(err)
+	ms.Equal(5, len(path))
+	ms.Equal(users[3].ID, path[3].ID)
+
+	// Too far away
+	_, err = FindFriendPath(ms.DB, users[0].ID, users[4].ID, 3, []uuid.UUID{users[5].ID})
+	ms.Equal(ErrNoPath, err)
+
+	// Same user
+	path, err = FindFriendPath(ms.DB, users[2].ID, users[2].ID, 6, nil)
+	ms.NoError(err)
+	ms.Equal(1, len(path))
+
+	// Too many users to search through
+	envy.Set("FRIEND_PATH_MAX_FRONTIER", "0")
+	defer envy.Set("FRIEND_PATH_MAX_FRONTIER", "")
+	_, err = FindFriendPath(ms.DB, users[0].ID, users[4].ID, 6, nil)
+	ms.Equal(ErrPathTooBroad, err)
+}
@@ -2,9 +2,13 @@ package models
 
 import (
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gobuffalo/envy"
 	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
 )
 
 // DB is a connection to your database to be used
@@ -20,3 +24,51 @@ func init() {
 	}
 	pop.Debug = env == "development"
 }
+
+// rowCount is used to scan the result of raw "SELECT COUNT(*) AS count"
+// queries.
+type rowCount struct {
+	Count int `db:"count"`
+}
+
+// idRow is used to scan the result of raw "SELECT ... AS id" queries.
+type idRow struct {
+	ID uuid.UUID `db:"id"`
+}
+
+// placeholders returns a list of n bind variables ("?, ?, ?"), to be used in
+// the "IN (...)" clauses of raw queries.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// uuidArray formats a list of IDs as a PostgreSQL array literal, to be bound
+// as a single "?::uuid[]" parameter (e.g. in "id = ANY(?::uuid[])") when the
+// list may be too long for a "IN (...)" clause.
+func uuidArray(ids []uuid.UUID) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return "{" + strings.Join(strs, ",") + "}"
+}
+
+// IntFromEnv reads an integer from the environment, falling back to given
+// default value if it's missing or invalid.
+func IntFromEnv(key string, def int) int {
+	i, err := strconv.Atoi(envy.Get(key, ""))
+	if err != nil {
+		return def
+	}
+	return i
+}
+
+// DurationFromEnv reads a duration from the environment, falling back to
+// given default value if it's missing or invalid.
+func DurationFromEnv(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(envy.Get(key, ""))
+	if err != nil {
+		return def
+	}
+	return d
+}
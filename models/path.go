@@ -0,0 +1,139 @@
+package models
+
+import (
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// ErrNoPath is returned when two users aren't connected by a chain of
+// friends within the maximum allowed length.
+var ErrNoPath = errors.New("No path found")
+
+// ErrPathTooBroad is returned when looking up a chain of friends would
+// visit too many users at once.
+var ErrPathTooBroad = errors.New("No path found: too many users to search through")
+
+// pathMaxFrontier is the maximum number of users whose friends are loaded at
+// once during a path search (default: 10000).
+func pathMaxFrontier() int {
+	return IntFromEnv("FRIEND_PATH_MAX_FRONTIER", 10000)
+}
+
+// pathSide is one half of a bidirectional breadth-first search
+type pathSide struct {
+	parents  map[uuid.UUID]uuid.UUID // How every visited user was reached
+	frontier []uuid.UUID             // Users visited during the last step
+}
+
+func newPathSide(start uuid.UUID) *pathSide {
+	return &pathSide{
+		parents:  map[uuid.UUID]uuid.UUID{start: uuid.Nil},
+		frontier: []uuid.UUID{start},
+	}
+}
+
+// expand visits the friends of the users in the frontier, and returns the
+// first one that was already visited by the other side, if any. The frontier
+// is passed as a single array parameter, so that its size isn't bound by the
+// maximum number of query parameters, but it is capped nonetheless.
+func (s *pathSide) expand(tx *pop.Connection, other *pathSide, excluded map[uuid.UUID]bool) (uuid.UUID, bool, error) {
+	if len(s.frontier) > pathMaxFrontier() {
+		return uuid.Nil, false, ErrPathTooBroad
+	}
+
+	fs := []Friendship{}
+	q := tx.Where("user_id = ANY(?::uuid[])", uuidArray(s.frontier))
+	if err := q.Order("user_id, friend_id").All(&fs); err != nil {
+		return uuid.Nil, false, err
+	}
+
+	s.frontier = s.frontier[:0]
+	for _, f := range fs {
+		if excluded[f.FriendID] {
+			continue
+		}
+		if _, seen := s.parents[f.FriendID]; seen {
+			continue
+		}
+		s.parents[f.FriendID] = f.UserID
+		if _, met := other.parents[f.FriendID]; met {
+			return f.FriendID, true, nil
+		}
+		s.frontier = append(s.frontier, f.FriendID)
+	}
+	return uuid.Nil, false, nil
+}
+
+// walk lists the users from given one back to the start of this side
+func (s *pathSide) walk(from uuid.UUID) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for id := from; id != uuid.Nil; id = s.parents[id] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// FindFriendPath looks up the shortest chain of friends between two users,
+// using a bidirectional breadth-first search over the friendships table.
+//
+// The path is made of at most maxDepth friendships, and never goes through
+// the excluded users. It starts with the "from" user and ends with the "to"
+// user. ErrNoPath is returned if there's no such path.
+func FindFriendPath(tx *pop.Connection, fromID, toID uuid.UUID, maxDepth int, excluded []uuid.UUID) (Users, error) {
+	skip := make(map[uuid.UUID]bool, len(excluded))
+	for _, id := range excluded {
+		skip[id] = true
+	}
+
+	forward, backward := newPathSide(fromID), newPathSide(toID)
+	meeting, found := fromID, fromID == toID
+	for depth := 0; !found && depth < maxDepth; depth++ {
+		if len(forward.frontier) == 0 || len(backward.frontier) == 0 {
+			break
+		}
+
+		// Always expand the smallest side
+		var err error
+		if len(forward.frontier) <= len(backward.frontier) {
+			meeting, found, err = forward.expand(tx, backward, skip)
+		} else {
+			meeting, found, err = backward.expand(tx, forward, skip)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, ErrNoPath
+	}
+
+	// Stitch both halves together
+	head := forward.walk(meeting)
+	ids := make([]uuid.UUID, 0, len(head))
+	for i := len(head) - 1; i >= 0; i-- {
+		ids = append(ids, head[i])
+	}
+	if meeting != toID {
+		ids = append(ids, backward.walk(backward.parents[meeting])...)
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	users := Users{}
+	if err := tx.Where("id IN (?)", args...).All(&users); err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	path := make(Users, len(ids))
+	for i, id := range ids {
+		path[i] = byID[id]
+	}
+	return path, nil
+}
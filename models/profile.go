@@ -0,0 +1,216 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+)
+
+// ProfileRelations selects the relations loaded by LoadProfile
+type ProfileRelations struct {
+	Friends     bool // Friends, most recent first
+	OutRequests bool // Pending outgoing friend requests, with their recipient
+	InRequests  bool // Pending incoming friend requests, with their sender
+	Reports     bool // Reports about the user, with their reporter
+}
+
+// profileRow is used to scan the relations aggregated by LoadProfile
+type profileRow struct {
+	Friends     []byte `db:"friends"`
+	OutRequests []byte `db:"out_requests"`
+	InRequests  []byte `db:"in_requests"`
+	Reports     []byte `db:"reports"`
+}
+
+// jsonTime formats a timestamp column the way encoding/json expects it
+func jsonTime(col string) string {
+	return fmt.Sprintf(`to_char(%s, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`, col)
+}
+
+// jsonObject builds a JSON object out of (key, SQL expression) pairs. Keys
+// are constant identifiers, written as is in the query.
+func jsonObject(pairs ...string) string {
+	args := make([]string, len(pairs))
+	for i, p := range pairs {
+		if i%2 == 0 {
+			p = "'" + p + "'"
+		}
+		args[i] = p
+	}
+	return "json_build_object(" + strings.Join(args, ", ") + ")"
+}
+
+// userJSON builds the public JSON representation of the user aliased as
+// given table.
+func userJSON(t string) string {
+	return jsonObject(
+		"id", t+".id",
+		"created_at", jsonTime(t+".created_at"),
+		"updated_at", jsonTime(t+".updated_at"),
+		"login", t+".login",
+		"info", t+".info",
+		"admin", t+".admin",
+	)
+}
+
+// requestJSON builds the JSON representation of the friend request aliased
+// as "fr", along with its counterpart user (aliased as "u") as given key.
+func requestJSON(counterpart string) string {
+	return jsonObject(
+		"id", "fr.id",
+		"created_at", jsonTime("fr.created_at"),
+		"updated_at", jsonTime("fr.updated_at"),
+		counterpart, userJSON("u"),
+		"message", "fr.message",
+		"status", "fr.status",
+		"responded_at", jsonTime("fr.responded_at"),
+	)
+}
+
+// jsonList aggregates a JSON expression over the rows of a query into an
+// array (an empty one if there is no row).
+func jsonList(expr, order, from string) string {
+	return fmt.Sprintf("(SELECT COALESCE(json_agg(%s ORDER BY %s), '[]') %s)", expr, order, from)
+}
+
+// LoadProfile fetches the selected relations of a user and fills the
+// corresponding lists. Unlike the various Fetch* methods, all relations are
+// aggregated as JSON in a single query. Reports are also given their
+// reporter's dismissal rate, which takes a second query.
+func (u *User) LoadProfile(tx *pop.Connection, rels ProfileRelations) error {
+	columns := []string{}
+	args := []interface{}{}
+	add := func(selected bool, name, list string, listArgs ...interface{}) {
+		if !selected {
+			columns = append(columns, "NULL::json AS "+name)
+			return
+		}
+		columns = append(columns, list+" AS "+name)
+		args = append(args, listArgs...)
+	}
+
+	add(rels.Friends, "friends", jsonList(
+		userJSON("u"), "fs.created_at DESC, u.id DESC",
+		`FROM friendships AS fs INNER JOIN users AS u ON u.id = fs.friend_id
+		WHERE fs.user_id = ?`,
+	), u.ID)
+	add(rels.OutRequests, "out_requests", jsonList(
+		requestJSON("to"), "fr.created_at DESC, fr.id DESC",
+		`FROM friend_requests AS fr INNER JOIN users AS u ON u.id = fr.to_id
+		WHERE fr.from_id = ? AND fr.status = ?`,
+	), u.ID, RequestPending)
+	add(rels.InRequests, "in_requests", jsonList(
+		requestJSON("from"), "fr.created_at DESC, fr.id DESC",
+		`FROM friend_requests AS fr INNER JOIN users AS u ON u.id = fr.from_id
+		WHERE fr.to_id = ? AND fr.status = ?`,
+	), u.ID, RequestPending)
+	add(rels.Reports, "reports", jsonList(
+		jsonObject(
+			"id", "r.id",
+			"created_at", jsonTime("r.created_at"),
+			"updated_at", jsonTime("r.updated_at"),
+			"by", userJSON("u"),
+			"category", "r.category",
+			"info", "r.info",
+			"status", "r.status",
+			"assignee_id", "r.assignee_id",
+			"resolution", "r.resolution",
+			"resolved_at", jsonTime("r.resolved_at"),
+		),
+		"r.created_at DESC, r.id DESC",
+		`FROM reports AS r INNER JOIN users AS u ON u.id = r.by_id
+		WHERE r.about_id = ?`,
+	), u.ID)
+
+	row := &profileRow{}
+	if err := tx.RawQuery("SELECT "+strings.Join(columns, ", "), args...).First(row); err != nil {
+		return err
+	}
+
+	if rels.Friends {
+		if err := json.Unmarshal(row.Friends, &u.Friends); err != nil {
+			return err
+		}
+	}
+	if rels.OutRequests {
+		if err := json.Unmarshal(row.OutRequests, &u.OutRequests); err != nil {
+			return err
+		}
+		for i := range u.OutRequests {
+			u.OutRequests[i].FromID = u.ID
+			u.OutRequests[i].ToID = u.OutRequests[i].To.ID
+		}
+	}
+	if rels.InRequests {
+		if err := json.Unmarshal(row.InRequests, &u.InRequests); err != nil {
+			return err
+		}
+		for i := range u.InRequests {
+			u.InRequests[i].FromID = u.InRequests[i].From.ID
+			u.InRequests[i].ToID = u.ID
+		}
+	}
+	if rels.Reports {
+		if err := json.Unmarshal(row.Reports, &u.Reports); err != nil {
+			return err
+		}
+		for i := range u.Reports {
+			u.Reports[i].ByID = u.Reports[i].By.ID
+			u.Reports[i].AboutID = u.ID
+		}
+		return u.Reports.FetchDismissalRates(tx)
+	}
+	return nil
+}
+
+// ProfileVersion summarizes the state of everything a user's profile is
+// built from. It changes whenever any of it is created, updated or deleted,
+// and is much cheaper to compute than the profile itself.
+type ProfileVersion struct {
+	ModifiedAt time.Time `db:"modified_at"` // Date of the latest change
+	Count      int       `db:"count"`       // Number of rows involved, which tells deletions
+}
+
+// FetchProfileVersion computes the version of a user's profile, as seen by
+// given viewer (or uuid.Nil if the profile isn't personalized). It covers:
+//
+// - the user, their roles and sanctions,
+// - their friends, friend requests and reports about them, along with the
+// users these refer to,
+// - the other reports made by the same reporters, which their dismissal rate
+// is computed from,
+// - the viewer, their roles and friends (which the number of mutual friends
+// is computed from).
+func FetchProfileVersion(tx *pop.Connection, userID, viewerID uuid.UUID) (*ProfileVersion, error) {
+	v := &ProfileVersion{}
+	err := tx.RawQuery(
+		`SELECT MAX(t) AS modified_at, SUM(n)::int AS count FROM (
+			SELECT updated_at AS t, 1 AS n FROM users WHERE id IN (?, ?)
+			UNION ALL
+			SELECT MAX(created_at), COUNT(*) FROM user_roles WHERE user_id IN (?, ?)
+			UNION ALL
+			SELECT MAX(updated_at), COUNT(*) FROM sanctions WHERE user_id = ?
+			UNION ALL
+			SELECT MAX(GREATEST(fs.created_at, u.updated_at)), COUNT(*)
+			FROM friendships AS fs INNER JOIN users AS u ON u.id = fs.friend_id
+			WHERE fs.user_id IN (?, ?)
+			UNION ALL
+			SELECT MAX(GREATEST(fr.updated_at, u.updated_at)), COUNT(*)
+			FROM friend_requests AS fr INNER JOIN users AS u ON u.id IN (fr.from_id, fr.to_id)
+			WHERE ? IN (fr.from_id, fr.to_id)
+			UNION ALL
+			SELECT MAX(GREATEST(r.updated_at, u.updated_at)), COUNT(*)
+			FROM reports AS r INNER JOIN users AS u ON u.id = r.by_id
+			WHERE r.by_id IN (SELECT by_id FROM reports WHERE about_id = ?)
+		) AS changes`,
+		userID, viewerID, userID, viewerID, userID, userID, viewerID, userID, userID,
+	).First(v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/brianvoe/gofakeit"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/suite"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// seedProfile creates a user with given number of friends, pending friend
+// requests (in each direction) and reports about them.
+func (ms *ModelSuite) seedProfile(friends, requests, reports int) *User {
+	user := ms.createRandomUser()
+	for i := 0; i < friends+2*requests+reports; i++ {
+		other := ms.createRandomUser()
+		switch {
+		case i < friends:
+			req, err := user.SendRequest(ms.DB, other, "")
+			ms.NoError(err)
+			ms.NoError(req.Accept(ms.DB))
+		case i < friends+requests:
+			_, err := user.SendRequest(ms.DB, other, gofakeit.Sentence(5))
+			ms.NoError(err)
+		case i < friends+2*requests:
+			_, err := other.SendRequest(ms.DB, user, gofakeit.Sentence(5))
+			ms.NoError(err)
+		default:
+			report := &Report{ByID: other.ID, AboutID: user.ID, Info: gofakeit.Sentence(5)}
+			verrs, err := report.Create(ms.DB)
+			ms.NoError(err)
+			ms.Falsef(verrs.HasAny(), verrs.String())
+		}
+	}
+	return user
+}
+
+// fetchProfile loads a user's profile one relation at a time, the way it
+// was done before LoadProfile.
+func fetchProfile(tx *pop.Connection, u *User) error {
+	if err := u.FetchFriends(tx); err != nil {
+		return err
+	}
+	if err := u.FetchRequests(tx); err != nil {
+		return err
+	}
+	if err := u.FetchReports(tx); err != nil {
+		return err
+	}
+	return u.Reports.FetchDismissalRates(tx)
+}
+
+var allRelations = ProfileRelations{Friends: true, OutRequests: true, InRequests: true, Reports: true}
+
+func (ms *ModelSuite) Test_User_LoadProfile() {
+	user := ms.seedProfile(3, 2, 2)
+
+	// One reporter had their report dismissed, the other one resolved
+	reports := Reports{}
+	ms.NoError(ms.DB.Where("about_id = ?", user.ID).Order("created_at").All(&reports))
+	ms.NoError(reports[0].Resolve(ms.DB, ReportDismissed, ""))
+	ms.NoError(reports[1].Resolve(ms.DB, ReportResolved, ""))
+	rates := map[uuid.UUID]float64{reports[0].ByID: 1, reports[1].ByID: 0}
+
+	fetched := *user
+	ms.NoError(fetchProfile(ms.DB, &fetched))
+	// Fetch* methods don't sort requests and reports
+	for _, reqs := range []FriendRequests{fetched.OutRequests, fetched.InRequests} {
+		sort.Slice(reqs, func(i, j int) bool { return reqs[i].CreatedAt.After(reqs[j].CreatedAt) })
+	}
+	sort.Slice(fetched.Reports, func(i, j int) bool {
+		return fetched.Reports[i].CreatedAt.After(fetched.Reports[j].CreatedAt)
+	})
+
+	loaded := *user
+	ms.NoError(loaded.LoadProfile(ms.DB, allRelations))
+	ms.Equal(3, len(loaded.Friends))
+	ms.Equal(2, len(loaded.OutRequests))
+	ms.Equal(2, len(loaded.InRequests))
+	ms.Equal(2, len(loaded.Reports))
+	ms.Equal(user.ID, loaded.Reports[0].AboutID)
+	for _, r := range loaded.Reports {
+		ms.Equal(r.By.ID, r.ByID)
+		ms.NotNil(r.ReporterDismissalRate)
+		ms.InDelta(rates[r.ByID], *r.ReporterDismissalRate, 0.001)
+	}
+
+	expected, err := json.Marshal(fetched)
+	ms.NoError(err)
+	actual, err := json.Marshal(loaded)
+	ms.NoError(err)
+	ms.JSONEq(string(expected), string(actual))
+
+	// Only the selected relations are loaded
+	partial := *user
+	ms.NoError(partial.LoadProfile(ms.DB, ProfileRelations{InRequests: true}))
+	ms.Nil(partial.Friends)
+	ms.Nil(partial.OutRequests)
+	ms.Nil(partial.Reports)
+	ms.Equal(2, len(partial.InRequests))
+	ms.Equal(user.ID, partial.InRequests[0].ToID)
+	ms.Equal(partial.InRequests[0].From.ID, partial.InRequests[0].FromID)
+
+	// Empty relations
+	lonely := ms.seedProfile(0, 0, 0)
+	ms.NoError(lonely.LoadProfile(ms.DB, allRelations))
+	ms.Empty(lonely.Friends)
+	ms.Empty(lonely.Reports)
+}
+
+// benchmarkProfile loads the profile of a user with 50 friends, 10 pending
+// requests in each direction and 10 reports, using given loader. Benchmarks
+// can't be run as part of the suite, so its fixtures are used in a
+// transaction that is rolled back afterwards.
+func benchmarkProfile(b *testing.B, load func(tx *pop.Connection, u *User) error) {
+	ms := &ModelSuite{suite.NewModel()}
+	ms.Assertions = require.New(b)
+	err := ms.DB.Rollback(func(tx *pop.Connection) {
+		ms.DB = tx
+		user := ms.seedProfile(50, 10, 10)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			u := *user
+			if err := load(tx, &u); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+	})
+	if err != nil {
+		b.Fatal(errors.WithStack(err))
+	}
+}
+
+func Benchmark_User_FetchProfile(b *testing.B) {
+	benchmarkProfile(b, fetchProfile)
+}
+
+func Benchmark_User_LoadProfile(b *testing.B) {
+	benchmarkProfile(b, func(tx *pop.Connection, u *User) error {
+		return u.LoadProfile(tx, allRelations)
+	})
+}
@@ -2,34 +2,136 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop"
 	"github.com/gobuffalo/validate"
 	"github.com/gobuffalo/validate/validators"
 	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
 )
 
+// Report statuses
+const (
+	ReportOpen      = "open"      // Waiting for a moderator
+	ReportInReview  = "in_review" // Assigned to a moderator
+	ReportResolved  = "resolved"  // Action was taken
+	ReportDismissed = "dismissed" // No action was needed
+)
+
+// ReportStatuses lists all valid report statuses
+var ReportStatuses = []string{
+	ReportOpen,
+	ReportInReview,
+	ReportResolved,
+	ReportDismissed,
+}
+
+// Report categories
+const (
+	CategorySpam                 = "spam"
+	CategoryHarassment           = "harassment"
+	CategoryImpersonation        = "impersonation"
+	CategoryInappropriateContent = "inappropriate_content"
+	CategoryOther                = "other" // Requires some info
+)
+
+// ReportCategories lists all valid report categories
+var ReportCategories = []string{
+	CategorySpam,
+	CategoryHarassment,
+	CategoryImpersonation,
+	CategoryInappropriateContent,
+	CategoryOther,
+}
+
+// ValidReportCategory tells whether given string is a valid report category
+func ValidReportCategory(category string) bool {
+	for _, c := range ReportCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidReportStatus tells whether given string is a valid report status
+func ValidReportStatus(status string) bool {
+	for _, s := range ReportStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Validation error keys of the anti-abuse checks performed on report
+// creation, so that callers can tell them apart from invalid reports.
+const (
+	ReportDuplicateKey = "duplicate"
+	ReportQuotaKey     = "quota"
+)
+
+// ReportsDailyQuota is the maximum number of reports a user can file within
+// 24 hours, read from the REPORTS_DAILY_QUOTA environment variable
+// (default: 10).
+func ReportsDailyQuota() int {
+	return IntFromEnv("REPORTS_DAILY_QUOTA", 10)
+}
+
+// ErrReportClosed is returned when trying to assign or resolve a report
+// that was already resolved or dismissed.
+var ErrReportClosed = errors.New("This report is already closed")
+
+// ErrInvalidResolution is returned when resolving a report with a status
+// other than "resolved" or "dismissed".
+var ErrInvalidResolution = errors.New("A report can only be resolved or dismissed")
+
 // "Light" report model used by users during creation.
 type LightReport struct {
-	Info string `json:"info"` // Reason why the user is reported.
+	Category string `json:"category" enums:"spam,harassment,impersonation,inappropriate_content,other"` // Defaults to "other"
+	Info     string `json:"info"`                                                                       // Details, mandatory for the "other" category
+}
+
+// ReportAssignment holds the moderator a report is assigned to
+type ReportAssignment struct {
+	AssigneeID uuid.UUID `json:"assignee_id"` // Defaults to the caller
+}
+
+// ReportResolution holds the outcome of a report
+type ReportResolution struct {
+	Status     string `json:"status" enums:"resolved,dismissed"`
+	Resolution string `json:"resolution"` // Moderator's notes
 }
 
 // Report model struct
 type Report struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	ByID      uuid.UUID `json:"-" db:"by_id"`
-	By        *User     `json:"by,omitempty" db:"-" belongs_to:"user"`
-	AboutID   uuid.UUID `json:"-" db:"about_id"`
-	About     *User     `json:"about,omitempty" db:"-" belongs_to:"user"`
-	Info      string    `json:"info" db:"info"`
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	ByID       uuid.UUID  `json:"-" db:"by_id"`
+	By         *User      `json:"by,omitempty" db:"-" belongs_to:"user"`
+	AboutID    uuid.UUID  `json:"-" db:"about_id"`
+	About      *User      `json:"about,omitempty" db:"-" belongs_to:"user"`
+	Category   string     `json:"category" db:"category"`
+	Info       string     `json:"info" db:"info"`
+	Status     string     `json:"status" db:"status"`
+	AssigneeID nulls.UUID `json:"assignee_id" db:"assignee_id"`
+	Resolution string     `json:"resolution" db:"resolution"`
+	ResolvedAt nulls.Time `json:"resolved_at" db:"resolved_at"`
+
+	// Fraction of the reporter's closed reports that were dismissed, only
+	// computed for moderators (absent if none of their reports was closed).
+	ReporterDismissalRate *float64 `json:"reporter_dismissal_rate,omitempty" db:"-"`
 }
 
 // ReportFromLight Creates a full Report from its "light" version
 func ReportFromLight(light *LightReport) *Report {
 	return &Report{
-		Info: light.Info,
+		Category: light.Category,
+		Info:     light.Info,
 	}
 }
 
@@ -53,7 +155,14 @@ func (r *Report) Validate(tx *pop.Connection) (*validate.Errors, error) {
 	return validate.Validate(
 		&validators.UUIDIsPresent{Field: r.ByID, Name: "ByID"},
 		&validators.UUIDIsPresent{Field: r.AboutID, Name: "AboutID"},
-		&validators.StringIsPresent{Field: r.Info, Name: "Info"},
+		&validators.StringInclusion{Field: r.Category, Name: "Category", List: ReportCategories},
+		&validators.FuncValidator{
+			Field:   r.Category,
+			Name:    "Info",
+			Message: "Info can't be blank for category %s",
+			Fn:      func() bool { return r.Category != CategoryOther || r.Info != "" },
+		},
+		&validators.StringInclusion{Field: r.Status, Name: "Status", List: ReportStatuses},
 		&validators.FuncValidator{
 			Field:   r.AboutID.String(),
 			Name:    "AboutID",
@@ -63,7 +172,189 @@ func (r *Report) Validate(tx *pop.Connection) (*validate.Errors, error) {
 	), nil
 }
 
-// Create saves a newly created user into the database
+// ValidateCreate rejects duplicate reports (a reporter can only have one
+// open report about a given user) and enforces the reporters' daily quota.
+func (r *Report) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+
+	dups := Reports{}
+	err := tx.Where(
+		"by_id = ? AND about_id = ? AND status IN (?, ?)",
+		r.ByID, r.AboutID, ReportOpen, ReportInReview,
+	).All(&dups)
+	if err != nil {
+		return verrs, err
+	}
+	if len(dups) > 0 {
+		verrs.Add(
+			ReportDuplicateKey,
+			fmt.Sprintf("There's already an open report about this user (id=%s)", dups[0].ID),
+		)
+		return verrs, nil
+	}
+
+	count, err := tx.Where(
+		"by_id = ? AND created_at > ?", r.ByID, time.Now().Add(-24*time.Hour),
+	).Count("reports")
+	if err != nil {
+		return verrs, err
+	}
+	if quota := ReportsDailyQuota(); count >= quota {
+		verrs.Add(
+			ReportQuotaKey,
+			fmt.Sprintf("Can't file more than %d reports per day", quota),
+		)
+	}
+	return verrs, nil
+}
+
+// reporterStats is used to scan the track record of reporters
+type reporterStats struct {
+	ID        uuid.UUID `db:"id"`
+	Dismissed int       `db:"dismissed"`
+	Closed    int       `db:"closed"`
+}
+
+// FetchDismissalRates computes the ReporterDismissalRate of given reports,
+// in a single query.
+func FetchDismissalRates(tx *pop.Connection, reports ...*Report) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	ids := make([]interface{}, 0, len(reports))
+	for _, r := range reports {
+		ids = append(ids, r.ByID)
+	}
+
+	stats := []reporterStats{}
+	err := tx.RawQuery(
+		fmt.Sprintf(
+			`SELECT
+				by_id AS id,
+				COUNT(*) FILTER (WHERE status = ?) AS dismissed,
+				COUNT(*) FILTER (WHERE status IN (?, ?)) AS closed
+			FROM reports
+			WHERE by_id IN (%s)
+			GROUP BY by_id`,
+			placeholders(len(ids)),
+		),
+		append([]interface{}{ReportDismissed, ReportResolved, ReportDismissed}, ids...)...,
+	).All(&stats)
+	if err != nil {
+		return err
+	}
+
+	rates := make(map[uuid.UUID]float64, len(stats))
+	for _, s := range stats {
+		if s.Closed > 0 {
+			rates[s.ID] = float64(s.Dismissed) / float64(s.Closed)
+		}
+	}
+	for _, r := range reports {
+		if rate, ok := rates[r.ByID]; ok {
+			r.ReporterDismissalRate = &rate
+		}
+	}
+	return nil
+}
+
+// FetchDismissalRates computes the ReporterDismissalRate of all reports
+func (r Reports) FetchDismissalRates(tx *pop.Connection) error {
+	reports := make([]*Report, len(r))
+	for i := range r {
+		reports[i] = &r[i]
+	}
+	return FetchDismissalRates(tx, reports...)
+}
+
+// CategoryCount is the number of reports in a category
+type CategoryCount struct {
+	Category string `json:"category" db:"category"`
+	Count    int    `json:"count" db:"count"`
+}
+
+// CategoryCounts is a collection of CategoryCount.
+type CategoryCounts []CategoryCount
+
+// TableName tells pop that category counts are computed over reports
+func (c CategoryCounts) TableName() string {
+	return "reports"
+}
+
+// CountReportsByCategory counts the reports matching given query in each
+// category. Categories without any report are left out.
+func CountReportsByCategory(q *pop.Query) (CategoryCounts, error) {
+	counts := CategoryCounts{}
+	err := q.Select("category", "COUNT(*) AS count").
+		GroupBy("category").
+		Order("category").
+		All(&counts)
+	return counts, err
+}
+
+// Create saves a newly created report into the database.
+// Reports without a category fall into the "other" category. The reported
+// user may get flagged along the way (see escalate).
 func (r *Report) Create(tx *pop.Connection) (*validate.Errors, error) {
-	return tx.ValidateAndCreate(r)
+	if r.Category == "" {
+		r.Category = CategoryOther
+	}
+	r.Status = ReportOpen
+	verrs, err := tx.ValidateAndCreate(r)
+	if err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+	return verrs, escalate(tx, r.AboutID)
+}
+
+// Closed tells whether the report was resolved or dismissed
+func (r *Report) Closed() bool {
+	return r.Status == ReportResolved || r.Status == ReportDismissed
+}
+
+// Assign assigns an open report to a moderator, and puts it in review.
+// The status is checked by the query itself, so that a report can't be
+// reassigned while it is being closed.
+func (r *Report) Assign(tx *pop.Connection, assigneeID uuid.UUID) error {
+	now := time.Now()
+	count, err := tx.RawQuery(
+		`UPDATE reports SET status = ?, assignee_id = ?, updated_at = ?
+		WHERE id = ? AND status IN (?, ?)`,
+		ReportInReview, assigneeID, now, r.ID, ReportOpen, ReportInReview,
+	).ExecWithCount()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrReportClosed
+	}
+	r.Status = ReportInReview
+	r.AssigneeID = nulls.NewUUID(assigneeID)
+	r.UpdatedAt = now
+	return nil
+}
+
+// Resolve closes a report with given status ("resolved" or "dismissed") and
+// resolution notes.
+func (r *Report) Resolve(tx *pop.Connection, status, resolution string) error {
+	if status != ReportResolved && status != ReportDismissed {
+		return ErrInvalidResolution
+	}
+	now := time.Now()
+	count, err := tx.RawQuery(
+		`UPDATE reports SET status = ?, resolution = ?, resolved_at = ?, updated_at = ?
+		WHERE id = ? AND status IN (?, ?)`,
+		status, resolution, now, now, r.ID, ReportOpen, ReportInReview,
+	).ExecWithCount()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrReportClosed
+	}
+	r.Status = status
+	r.Resolution = resolution
+	r.ResolvedAt = nulls.NewTime(now)
+	r.UpdatedAt = now
+	return nil
 }
@@ -1,5 +1,7 @@
 package models
 
+import "github.com/gobuffalo/envy"
+
 func (ms *ModelSuite) Test_Report_Create() {
 	count, err := ms.DB.Count("reports")
 	ms.NoError(err)
@@ -63,3 +65,139 @@ func (ms *ModelSuite) Test_Report_Validate() {
 	ms.NoError(err)
 	ms.Truef(verrs.HasAny(), "Expected validation error (subject_id == user_id)")
 }
+
+func (ms *ModelSuite) Test_Report_Lifecycle() {
+	user := ms.createRandomUser()
+	other := ms.createRandomUser()
+	moderator := ms.createRandomUser()
+
+	report := &Report{
+		ByID:    user.ID,
+		AboutID: other.ID,
+		Info:    "Spam",
+	}
+	verrs, err := report.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+	ms.Equal(ReportOpen, report.Status)
+	ms.False(report.AssigneeID.Valid)
+
+	err = report.Assign(ms.DB, moderator.ID)
+	ms.NoError(err)
+	ms.Equal(ReportInReview, report.Status)
+	ms.Equal(moderator.ID, report.AssigneeID.UUID)
+
+	err = report.Resolve(ms.DB, ReportOpen, "")
+	ms.Equal(ErrInvalidResolution, err)
+
+	err = report.Resolve(ms.DB, ReportDismissed, "Not spam")
+	ms.NoError(err)
+	ms.True(report.Closed())
+
+	stored := &Report{}
+	err = ms.DB.Find(stored, report.ID)
+	ms.NoError(err)
+	ms.Equal(ReportDismissed, stored.Status)
+	ms.Equal("Not spam", stored.Resolution)
+	ms.True(stored.ResolvedAt.Valid)
+
+	// Closed reports can't be reassigned or resolved again
+	ms.Equal(ErrReportClosed, report.Assign(ms.DB, moderator.ID))
+	ms.Equal(ErrReportClosed, report.Resolve(ms.DB, ReportResolved, ""))
+}
+
+func (ms *ModelSuite) Test_Report_Category() {
+	user := ms.createRandomUser()
+	other := ms.createRandomUser()
+
+	report := &Report{ByID: user.ID, AboutID: other.ID, Category: "rudeness"}
+	verrs, err := report.Validate(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny(), "Expected validation error (invalid category)")
+
+	report.Category = CategoryOther
+	verrs, err = report.Validate(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny(), "Expected validation error (other category without info)")
+
+	// Info is optional otherwise
+	for _, category := range []string{CategorySpam, CategorySpam, CategoryHarassment} {
+		reporter := ms.createRandomUser()
+		report := &Report{ByID: reporter.ID, AboutID: other.ID, Category: category}
+		verrs, err := report.Create(ms.DB)
+		ms.NoError(err)
+		ms.Falsef(verrs.HasAny(), verrs.String())
+	}
+
+	counts, err := CountReportsByCategory(ms.DB.Q())
+	ms.NoError(err)
+	ms.Equal(CategoryCounts{
+		{Category: CategoryHarassment, Count: 1},
+		{Category: CategorySpam, Count: 2},
+	}, counts)
+}
+
+func (ms *ModelSuite) Test_Report_AntiAbuse() {
+	user := ms.createRandomUser()
+	other := ms.createRandomUser()
+
+	report := &Report{ByID: user.ID, AboutID: other.ID, Category: CategorySpam}
+	verrs, err := report.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+
+	// Only one open report about the same user
+	dup := &Report{ByID: user.ID, AboutID: other.ID, Category: CategoryHarassment}
+	verrs, err = dup.Create(ms.DB)
+	ms.NoError(err)
+	ms.NotEmpty(verrs.Get(ReportDuplicateKey))
+
+	// ... but a new one can be filed once it's closed
+	ms.NoError(report.Resolve(ms.DB, ReportDismissed, ""))
+	verrs, err = dup.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+
+	// Daily quota
+	envy.Set("REPORTS_DAILY_QUOTA", "3")
+	defer envy.Set("REPORTS_DAILY_QUOTA", "")
+
+	third := &Report{ByID: user.ID, AboutID: ms.createRandomUser().ID, Category: CategorySpam}
+	verrs, err = third.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+
+	fourth := &Report{ByID: user.ID, AboutID: ms.createRandomUser().ID, Category: CategorySpam}
+	verrs, err = fourth.Create(ms.DB)
+	ms.NoError(err)
+	ms.NotEmpty(verrs.Get(ReportQuotaKey))
+}
+
+func (ms *ModelSuite) Test_FetchDismissalRates() {
+	user := ms.createRandomUser()
+	newcomer := ms.createRandomUser()
+
+	reports := Reports{}
+	for _, status := range []string{ReportDismissed, ReportResolved, ReportDismissed, ReportDismissed, ""} {
+		report := &Report{ByID: user.ID, AboutID: ms.createRandomUser().ID, Category: CategorySpam}
+		verrs, err := report.Create(ms.DB)
+		ms.NoError(err)
+		ms.Falsef(verrs.HasAny(), verrs.String())
+		if status != "" {
+			ms.NoError(report.Resolve(ms.DB, status, ""))
+		}
+		reports = append(reports, *report)
+	}
+	fresh := &Report{ByID: newcomer.ID, AboutID: user.ID, Category: CategorySpam}
+	verrs, err := fresh.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+	reports = append(reports, *fresh)
+
+	ms.NoError(reports.FetchDismissalRates(ms.DB))
+	for _, r := range reports[:5] {
+		ms.NotNil(r.ReporterDismissalRate)
+		ms.InDelta(0.75, *r.ReporterDismissalRate, 0.001)
+	}
+	ms.Nil(reports[5].ReporterDismissalRate, "None of the newcomer's reports was closed")
+}
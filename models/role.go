@@ -0,0 +1,199 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// Role of a user.
+//
+// Every user implicitly has the "user" role, and the "admin" role is
+// materialized by the User.Admin flag. Other roles are stored in the
+// "user_roles" table.
+type Role string
+
+// Available roles
+const (
+	RoleUser      Role = "user"
+	RoleSupport   Role = "support"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Permission is a named privilege granted by one or several roles
+type Permission string
+
+// Available permissions
+const (
+	PermUsersRead       Permission = "users:read"       // See other users' private info
+	PermUsersUpdate     Permission = "users:update"     // Edit other users' profiles
+	PermUsersDelete     Permission = "users:delete"     // Delete other users
+	PermUsersPromote    Permission = "users:promote"    // Grant and revoke roles
+	PermUsersSanction   Permission = "users:sanction"   // Warn, suspend and ban users
+	PermReportsRead     Permission = "reports:read"     // Read moderation reports
+	PermReportsModerate Permission = "reports:moderate" // Assign and resolve reports
+	PermAuditRead       Permission = "audit:read"       // Read the audit log
+)
+
+// rolePermissions maps each role to the permissions it grants
+var rolePermissions = map[Role][]Permission{
+	RoleUser:      {},
+	RoleSupport:   {PermUsersRead},
+	RoleModerator: {PermReportsRead, PermReportsModerate, PermUsersSanction},
+	RoleAdmin: {
+		PermUsersRead,
+		PermUsersUpdate,
+		PermUsersDelete,
+		PermUsersPromote,
+		PermUsersSanction,
+		PermReportsRead,
+		PermReportsModerate,
+		PermAuditRead,
+	},
+}
+
+// ErrInvalidRole is returned when granting or revoking an unknown role
+var ErrInvalidRole = errors.New("Invalid role")
+
+// Valid tells whether the role exists
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Grants tells whether the role grants given permission
+func (r Role) Grants(perm Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// UserRole model struct, associating a user with an extra role
+type UserRole struct {
+	CreatedAt time.Time `db:"created_at"`
+	UserID    uuid.UUID `db:"user_id"`
+	Role      Role      `db:"role"`
+}
+
+// HasRole tells whether the user has given role.
+// The user's roles must have been fetched beforehand.
+func (u *User) HasRole(role Role) bool {
+	switch role {
+	case RoleUser:
+		return true
+	case RoleAdmin:
+		return u.Admin
+	}
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can tells whether the user has given permission through any of their
+// roles. The user's roles must have been fetched beforehand.
+func (u *User) Can(perm Permission) bool {
+	for role := range rolePermissions {
+		if u.HasRole(role) && role.Grants(perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchRoles looks up a user's extra roles and fills its Roles list
+func (u *User) FetchRoles(tx *pop.Connection) error {
+	roles := []UserRole{}
+	err := tx.Where("user_id = ?", u.ID).Order("role").All(&roles)
+	if err != nil {
+		return err
+	}
+	u.Roles = make([]Role, 0, len(roles))
+	for _, r := range roles {
+		u.Roles = append(u.Roles, r.Role)
+	}
+	return nil
+}
+
+// GrantRole grants a role to the user.
+// Auth tokens issued to the user before the change are invalidated.
+func (u *User) GrantRole(tx *pop.Connection, role Role) error {
+	switch {
+	case !role.Valid():
+		return ErrInvalidRole
+	case u.HasRole(role):
+		return nil
+	case role == RoleAdmin:
+		u.Admin = true
+		return u.updatePrivileges(tx)
+	}
+	err := tx.RawQuery(
+		"INSERT INTO user_roles (user_id, role) VALUES (?, ?) ON CONFLICT DO NOTHING",
+		u.ID, role,
+	).Exec()
+	if err != nil {
+		return err
+	}
+	u.Roles = append(u.Roles, role)
+	return u.BumpTokenVersion(tx)
+}
+
+// RevokeRole revokes a role from the user.
+// Auth tokens issued to the user before the change are invalidated.
+func (u *User) RevokeRole(tx *pop.Connection, role Role) error {
+	switch {
+	case !role.Valid() || role == RoleUser:
+		return ErrInvalidRole
+	case !u.HasRole(role):
+		return nil
+	case role == RoleAdmin:
+		u.Admin = false
+		return u.updatePrivileges(tx)
+	}
+	err := tx.RawQuery(
+		"DELETE FROM user_roles WHERE user_id = ? AND role = ?", u.ID, role,
+	).Exec()
+	if err != nil {
+		return err
+	}
+	roles := u.Roles[:0]
+	for _, r := range u.Roles {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	u.Roles = roles
+	return u.BumpTokenVersion(tx)
+}
+
+// updatePrivileges saves a change of the user's Admin flag
+func (u *User) updatePrivileges(tx *pop.Connection) error {
+	verrs, err := u.Update(tx)
+	if err != nil {
+		return err
+	}
+	if verrs.HasAny() {
+		return verrs
+	}
+	return nil
+}
+
+// BumpTokenVersion invalidates all the auth tokens issued to the user so far
+func (u *User) BumpTokenVersion(tx *pop.Connection) error {
+	err := tx.RawQuery(
+		"UPDATE users SET token_version = token_version + 1 WHERE id = ?", u.ID,
+	).Exec()
+	if err != nil {
+		return err
+	}
+	u.TokenVersion++
+	return nil
+}
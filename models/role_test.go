@@ -0,0 +1,66 @@
+package models
+
+func (ms *ModelSuite) Test_Role_Permissions() {
+	ms.True(RoleAdmin.Grants(PermUsersPromote))
+	ms.True(RoleModerator.Grants(PermReportsRead))
+	ms.False(RoleModerator.Grants(PermUsersUpdate))
+	ms.True(RoleSupport.Grants(PermUsersRead))
+	ms.False(RoleUser.Grants(PermReportsRead))
+	ms.False(Role("overlord").Valid())
+}
+
+func (ms *ModelSuite) Test_User_GrantRole() {
+	u := ms.createRandomUser()
+	ms.False(u.Can(PermReportsRead))
+
+	err := u.GrantRole(ms.DB, RoleModerator)
+	ms.NoError(err)
+	ms.True(u.HasRole(RoleModerator))
+	ms.True(u.Can(PermReportsRead))
+	ms.False(u.Can(PermUsersDelete))
+	ms.Equal(1, u.TokenVersion)
+
+	// Granting twice is harmless
+	err = u.GrantRole(ms.DB, RoleModerator)
+	ms.NoError(err)
+
+	err = u.GrantRole(ms.DB, Role("overlord"))
+	ms.Equal(ErrInvalidRole, err)
+
+	stored := &User{}
+	err = ms.DB.Find(stored, u.ID)
+	ms.NoError(err)
+	ms.Equal(1, stored.TokenVersion)
+	err = stored.FetchRoles(ms.DB)
+	ms.NoError(err)
+	ms.Equal([]Role{RoleModerator}, stored.Roles)
+
+	// Admin role is materialized by the Admin flag
+	err = u.GrantRole(ms.DB, RoleAdmin)
+	ms.NoError(err)
+	ms.True(u.Admin)
+	ms.True(u.Can(PermUsersDelete))
+}
+
+func (ms *ModelSuite) Test_User_RevokeRole() {
+	u := ms.createRandomUser()
+	ms.NoError(u.GrantRole(ms.DB, RoleSupport))
+	ms.NoError(u.GrantRole(ms.DB, RoleAdmin))
+
+	err := u.RevokeRole(ms.DB, RoleAdmin)
+	ms.NoError(err)
+	ms.False(u.Admin)
+	ms.False(u.Can(PermUsersDelete))
+	ms.True(u.Can(PermUsersRead))
+
+	err = u.RevokeRole(ms.DB, RoleSupport)
+	ms.NoError(err)
+	ms.False(u.Can(PermUsersRead))
+
+	err = u.FetchRoles(ms.DB)
+	ms.NoError(err)
+	ms.Empty(u.Roles)
+
+	err = u.RevokeRole(ms.DB, RoleUser)
+	ms.Equal(ErrInvalidRole, err)
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// Sanction kinds
+const (
+	SanctionWarning    = "warning"    // No restriction, the user is only notified
+	SanctionSuspension = "suspension" // Read-only access until the sanction expires
+	SanctionBan        = "ban"        // No access at all
+)
+
+// SanctionKinds lists all valid sanction kinds
+var SanctionKinds = []string{
+	SanctionWarning,
+	SanctionSuspension,
+	SanctionBan,
+}
+
+// ErrSanctionLifted is returned when lifting a sanction twice
+var ErrSanctionLifted = errors.New("This sanction was already lifted")
+
+// "Light" sanction model used by moderators during creation
+type LightSanction struct {
+	Kind      string     `json:"kind" enums:"warning,suspension,ban"`
+	Reason    string     `json:"reason"`     // Why the user is sanctioned
+	ExpiresAt nulls.Time `json:"expires_at"` // Mandatory for suspensions only
+	ReportID  nulls.UUID `json:"report_id"`  // Optional report that triggered the sanction
+}
+
+// Sanction model struct
+type Sanction struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	ByID      nulls.UUID `json:"by_id" db:"by_id"` // Null once the issuer's account is deleted
+	ReportID  nulls.UUID `json:"report_id" db:"report_id"`
+	Kind      string     `json:"kind" db:"kind"`
+	Reason    string     `json:"reason" db:"reason"`
+	ExpiresAt nulls.Time `json:"expires_at" db:"expires_at"`
+	LiftedAt  nulls.Time `json:"lifted_at" db:"lifted_at"`
+}
+
+// SanctionFromLight creates a full Sanction from its "light" version
+func SanctionFromLight(light *LightSanction) *Sanction {
+	return &Sanction{
+		Kind:      light.Kind,
+		Reason:    light.Reason,
+		ExpiresAt: light.ExpiresAt,
+		ReportID:  light.ReportID,
+	}
+}
+
+// String is not required by pop and may be deleted
+func (s Sanction) String() string {
+	js, _ := json.Marshal(s)
+	return string(js)
+}
+
+// Sanctions is not required by pop and may be deleted
+type Sanctions []Sanction
+
+// Validate a Sanction
+func (s *Sanction) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: s.UserID, Name: "UserID"},
+		&validators.UUIDIsPresent{Field: s.ByID.UUID, Name: "ByID"},
+		&validators.StringInclusion{Field: s.Kind, Name: "Kind", List: SanctionKinds},
+		&validators.StringIsPresent{Field: s.Reason, Name: "Reason"},
+		&validators.FuncValidator{
+			Field:   s.UserID.String(),
+			Name:    "UserID",
+			Message: "Can't sanction yourself (user_id: %s)",
+			Fn:      func() bool { return s.UserID != s.ByID.UUID },
+		},
+		&validators.FuncValidator{
+			Field:   s.Kind,
+			Name:    "ExpiresAt",
+			Message: "Suspensions, and only them, must expire in the future (kind: %s)",
+			Fn: func() bool {
+				if s.Kind == SanctionSuspension {
+					return s.ExpiresAt.Valid && s.ExpiresAt.Time.After(time.Now())
+				}
+				return !s.ExpiresAt.Valid
+			},
+		},
+	), nil
+}
+
+// ValidateCreate checks that the report a sanction refers to is about the
+// sanctioned user.
+func (s *Sanction) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if !s.ReportID.Valid {
+		return verrs, nil
+	}
+	exists, err := tx.Where(
+		"id = ? AND about_id = ?", s.ReportID.UUID, s.UserID,
+	).Exists("reports")
+	if err != nil {
+		return verrs, err
+	}
+	if !exists {
+		verrs.Add("report_id", "This report doesn't exist or isn't about this user")
+	}
+	return verrs, nil
+}
+
+// Create saves a new sanction into the database
+func (s *Sanction) Create(tx *pop.Connection) (*validate.Errors, error) {
+	return tx.ValidateAndCreate(s)
+}
+
+// Active tells whether the sanction is still in effect
+func (s *Sanction) Active() bool {
+	if s.LiftedAt.Valid {
+		return false
+	}
+	return !s.ExpiresAt.Valid || s.ExpiresAt.Time.After(time.Now())
+}
+
+// Lift ends a sanction before it expires
+func (s *Sanction) Lift(tx *pop.Connection) error {
+	now := time.Now()
+	count, err := tx.RawQuery(
+		"UPDATE sanctions SET lifted_at = ?, updated_at = ? WHERE id = ? AND lifted_at IS NULL",
+		now, now, s.ID,
+	).ExecWithCount()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSanctionLifted
+	}
+	s.LiftedAt = nulls.NewTime(now)
+	s.UpdatedAt = now
+	return nil
+}
+
+// ActiveSanction looks up the restriction currently in effect for a user:
+// a ban if any, or the suspension that ends last. Warnings are ignored, and
+// expired suspensions are lifted de facto. It returns nil if the user can
+// use the service normally.
+func ActiveSanction(tx *pop.Connection, userID uuid.UUID) (*Sanction, error) {
+	sanctions := Sanctions{}
+	err := tx.Where(
+		`user_id = ? AND kind IN (?, ?) AND lifted_at IS NULL
+		AND (expires_at IS NULL OR expires_at > ?)`,
+		userID, SanctionBan, SanctionSuspension, time.Now(),
+	).Order("expires_at DESC NULLS FIRST").Limit(1).All(&sanctions)
+	if err != nil {
+		return nil, err
+	}
+	if len(sanctions) == 0 {
+		return nil, nil
+	}
+	return &sanctions[0], nil
+}
+
+// FetchSanctions looks up all the sanctions a user received, and fills
+// its Sanctions list.
+func (u *User) FetchSanctions(tx *pop.Connection) error {
+	return tx.Where("user_id = ?", u.ID).Order("created_at desc").All(&u.Sanctions)
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+)
+
+func (ms *ModelSuite) Test_Sanction_Validate() {
+	user := ms.createRandomUser()
+	moderator := ms.createRandomUser()
+
+	// Suspensions must expire
+	sanction := &Sanction{
+		UserID: user.ID,
+		ByID:   nulls.NewUUID(moderator.ID),
+		Kind:   SanctionSuspension,
+		Reason: "Spam",
+	}
+	verrs, err := sanction.Create(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny(), "Expected validation error (no expiration)")
+
+	// Bans can't expire
+	sanction.Kind = SanctionBan
+	sanction.ExpiresAt = nulls.NewTime(time.Now().Add(time.Hour))
+	verrs, err = sanction.Create(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny(), "Expected validation error (expiring ban)")
+
+	// Only existing reports about the user can be referred to
+	report := &Report{ByID: user.ID, AboutID: moderator.ID, Info: "Mean"}
+	verrs, err = report.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+
+	sanction.Kind = SanctionWarning
+	sanction.ExpiresAt = nulls.Time{}
+	sanction.ReportID = nulls.NewUUID(report.ID)
+	verrs, err = sanction.Create(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny(), "Expected validation error (unrelated report)")
+
+	sanction.ReportID = nulls.UUID{}
+	verrs, err = sanction.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+}
+
+func (ms *ModelSuite) Test_ActiveSanction() {
+	user := ms.createRandomUser()
+	moderator := ms.createRandomUser()
+
+	create := func(kind string, expiresAt nulls.Time) *Sanction {
+		s := &Sanction{
+			UserID:    user.ID,
+			ByID:      nulls.NewUUID(moderator.ID),
+			Kind:      kind,
+			Reason:    "Spam",
+			ExpiresAt: expiresAt,
+		}
+		verrs, err := s.Create(ms.DB)
+		ms.NoError(err)
+		ms.Falsef(verrs.HasAny(), verrs.String())
+		return s
+	}
+
+	// Warnings don't restrict anything
+	create(SanctionWarning, nulls.Time{})
+	active, err := ActiveSanction(ms.DB, user.ID)
+	ms.NoError(err)
+	ms.Nil(active)
+
+	// Expired suspensions don't either
+	expired := create(SanctionSuspension, nulls.NewTime(time.Now().Add(time.Hour)))
+	err = ms.DB.RawQuery(
+		"UPDATE sanctions SET expires_at = ? WHERE id = ?",
+		time.Now().Add(-time.Minute), expired.ID,
+	).Exec()
+	ms.NoError(err)
+	active, err = ActiveSanction(ms.DB, user.ID)
+	ms.NoError(err)
+	ms.Nil(active)
+
+	suspension := create(SanctionSuspension, nulls.NewTime(time.Now().Add(time.Hour)))
+	active, err = ActiveSanction(ms.DB, user.ID)
+	ms.NoError(err)
+	ms.Equal(suspension.ID, active.ID)
+
+	// Bans prevail over suspensions
+	ban := create(SanctionBan, nulls.Time{})
+	active, err = ActiveSanction(ms.DB, user.ID)
+	ms.NoError(err)
+	ms.Equal(ban.ID, active.ID)
+
+	ms.NoError(ban.Lift(ms.DB))
+	ms.Equal(ErrSanctionLifted, ban.Lift(ms.DB))
+	active, err = ActiveSanction(ms.DB, user.ID)
+	ms.NoError(err)
+	ms.Equal(suspension.ID, active.ID)
+}
+
+func (ms *ModelSuite) Test_Sanction_IssuerDeleted() {
+	user := ms.createRandomUser()
+	moderator := ms.createRandomUser()
+
+	ban := &Sanction{UserID: user.ID, ByID: nulls.NewUUID(moderator.ID), Kind: SanctionBan, Reason: "Spam"}
+	verrs, err := ban.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+
+	// Deleting the moderator's account doesn't reinstate the user
+	ms.NoError(ms.DB.Destroy(moderator))
+	active, err := ActiveSanction(ms.DB, user.ID)
+	ms.NoError(err)
+	ms.NotNil(active)
+	ms.Equal(ban.ID, active.ID)
+	ms.False(active.ByID.Valid)
+}
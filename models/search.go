@@ -0,0 +1,48 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/pop"
+)
+
+// SearchConfig is the text search configuration used to index and search
+// users' info.
+const SearchConfig = "english"
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchUsersQuery narrows given query down to the users matching a search
+// term. Logins are matched case-insensitively, by prefix or by trigram
+// similarity, and info is matched with full-text search.
+func SearchUsersQuery(q *pop.Query, term string) *pop.Query {
+	lower, prefix := searchPatterns(term)
+	return q.Where(
+		`(lower(users.login) LIKE ? OR lower(users.login) % ?
+		OR to_tsvector('`+SearchConfig+`', users.info) @@ plainto_tsquery('`+SearchConfig+`', ?))`,
+		prefix, lower, strings.TrimSpace(term),
+	)
+}
+
+// UsersByRelevance orders given query by decreasing relevance with respect
+// to a search term. The relevance is computed by a joined subquery, so that
+// the term can be bound like in a WHERE clause.
+func UsersByRelevance(q *pop.Query, term string) *pop.Query {
+	lower, prefix := searchPatterns(term)
+	return q.InnerJoin(
+		`(SELECT id, CASE WHEN lower(login) LIKE ? THEN 1 ELSE 0 END
+			+ similarity(lower(login), ?)
+			+ ts_rank(to_tsvector('`+SearchConfig+`', info), plainto_tsquery('`+SearchConfig+`', ?)) AS relevance
+		FROM users) AS ranked`,
+		"ranked.id = users.id",
+		prefix, lower, strings.TrimSpace(term),
+	).Order("ranked.relevance DESC, users.login, users.id")
+}
+
+// searchPatterns returns the lowercase version of a search term, and the
+// LIKE pattern matching the logins it prefixes.
+func searchPatterns(term string) (string, string) {
+	lower := strings.ToLower(strings.TrimSpace(term))
+	return lower, likeEscaper.Replace(lower) + "%"
+}
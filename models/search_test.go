@@ -0,0 +1,50 @@
+package models
+
+// createUsers creates users with given logins and info
+func (ms *ModelSuite) createUsers(infos map[string]string) {
+	for login, info := range infos {
+		u := &User{Login: login, Info: info, Password: "password1234"}
+		verrs, err := u.Create(ms.DB)
+		ms.NoError(err)
+		ms.Falsef(verrs.HasAny(), verrs.String())
+	}
+}
+
+// searchLogins returns the logins of the users matching a search term
+func (ms *ModelSuite) searchLogins(term string) []string {
+	users := Users{}
+	q := UsersByRelevance(SearchUsersQuery(ms.DB.Q(), term), term)
+	ms.NoError(q.All(&users))
+	logins := make([]string, 0, len(users))
+	for _, u := range users {
+		logins = append(logins, u.Login)
+	}
+	return logins
+}
+
+func (ms *ModelSuite) Test_SearchUsersQuery() {
+	ms.createUsers(map[string]string{
+		"Alice":  "Live from Wonderland",
+		"Alicia": "Loves gardening",
+		"Bob":    "Not a sponge",
+		"Malice": "Gardens and flowers",
+		"Robert": "Sponge collector",
+	})
+
+	// Case-insensitive prefix
+	ms.Equal([]string{"Alice", "Alicia"}, ms.searchLogins("ALI"))
+
+	// Typos
+	ms.Equal([]string{"Alice", "Alicia"}, ms.searchLogins("alicee"))
+
+	// Full-text search on info
+	ms.ElementsMatch([]string{"Bob", "Robert"}, ms.searchLogins("sponges"))
+	ms.ElementsMatch([]string{"Alicia", "Malice"}, ms.searchLogins("garden"))
+
+	// Wildcards are taken literally
+	ms.Empty(ms.searchLogins("%"))
+	ms.Empty(ms.searchLogins("_o_"))
+
+	// So are quotes
+	ms.Empty(ms.searchLogins("o'; DROP TABLE users; --"))
+}
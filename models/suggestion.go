@@ -0,0 +1,138 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+)
+
+// SuggestionSamples is the number of mutual friends given as examples along
+// with each friend suggestion.
+const SuggestionSamples = 3
+
+// Suggestion is a user somebody may know, because they have friends in common.
+type Suggestion struct {
+	User          *User `json:"user"`
+	MutualCount   int   `json:"mutual_count"`   // Number of mutual friends
+	MutualFriends Users `json:"mutual_friends"` // A few of these mutual friends
+}
+
+// Suggestions is a collection of Suggestion.
+type Suggestions []Suggestion
+
+// suggestionRow is used to scan the ranking of suggested users
+type suggestionRow struct {
+	UserID      uuid.UUID `db:"user_id"`
+	MutualCount int       `db:"mutual_count"`
+}
+
+// suggestionSample is used to scan the sample mutual friends of suggested
+// users
+type suggestionSample struct {
+	UserID   uuid.UUID `db:"user_id"`
+	MutualID uuid.UUID `db:"mutual_id"`
+}
+
+// FetchSuggestions looks up the friends of a user's friends, ranked by the
+// number of friends they have in common with this user.
+//
+// Friends, users with a pending friend request to or from this user, and
+// users on either side of a block are left out. The given query is used for
+// pagination.
+func (u *User) FetchSuggestions(q *pop.Query) (Suggestions, error) {
+	rows := []suggestionRow{}
+	err := q.RawQuery(
+		`SELECT theirs.user_id AS user_id, COUNT(*) AS mutual_count
+		FROM friendships AS mine
+		INNER JOIN friendships AS theirs ON theirs.friend_id = mine.friend_id
+		WHERE
+			mine.user_id = ?
+			AND theirs.user_id <> ?
+			AND theirs.user_id NOT IN (
+				SELECT friend_id FROM friendships WHERE user_id = ?
+			)
+			AND theirs.user_id NOT IN (
+				SELECT to_id FROM friend_requests WHERE from_id = ? AND status = ?
+				UNION
+				SELECT from_id FROM friend_requests WHERE to_id = ? AND status = ?
+			)
+			AND theirs.user_id NOT IN (
+				SELECT blocked_id FROM blocks WHERE blocker_id = ?
+				UNION
+				SELECT blocker_id FROM blocks WHERE blocked_id = ?
+			)
+		GROUP BY theirs.user_id
+		ORDER BY mutual_count DESC, theirs.user_id`,
+		u.ID, u.ID, u.ID,
+		u.ID, RequestPending, u.ID, RequestPending,
+		u.ID, u.ID,
+	).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make(Suggestions, len(rows))
+	if len(rows) == 0 {
+		return suggestions, nil
+	}
+
+	ids := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.UserID)
+	}
+
+	// Pick a few mutual friends for every suggested user
+	samples := []suggestionSample{}
+	err = q.Connection.RawQuery(
+		fmt.Sprintf(
+			`SELECT user_id, mutual_id FROM (
+				SELECT
+					theirs.user_id AS user_id,
+					mine.friend_id AS mutual_id,
+					ROW_NUMBER() OVER (
+						PARTITION BY theirs.user_id ORDER BY mine.created_at
+					) AS sample_rank
+				FROM friendships AS mine
+				INNER JOIN friendships AS theirs ON theirs.friend_id = mine.friend_id
+				WHERE mine.user_id = ? AND theirs.user_id IN (%s)
+			) AS ranked
+			WHERE sample_rank <= ?`,
+			placeholders(len(ids)),
+		),
+		append(append([]interface{}{u.ID}, ids...), SuggestionSamples)...,
+	).All(&samples)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load suggested users and their sample mutual friends all at once
+	for _, sample := range samples {
+		ids = append(ids, sample.MutualID)
+	}
+	users := Users{}
+	if err := q.Connection.Where("id IN (?)", ids...).All(&users); err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+
+	index := make(map[uuid.UUID]int, len(rows))
+	for i, row := range rows {
+		suggestions[i] = Suggestion{
+			User:          byID[row.UserID],
+			MutualCount:   row.MutualCount,
+			MutualFriends: Users{},
+		}
+		index[row.UserID] = i
+	}
+	for _, sample := range samples {
+		s := &suggestions[index[sample.UserID]]
+		if friend, ok := byID[sample.MutualID]; ok {
+			s.MutualFriends = append(s.MutualFriends, *friend)
+		}
+	}
+	return suggestions, nil
+}
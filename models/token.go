@@ -0,0 +1,160 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// ErrTokenRevoked is returned when rotating a refresh token that was already
+// rotated or revoked, possibly by a concurrent request.
+var ErrTokenRevoked = errors.New("Refresh token has been revoked")
+
+// RefreshToken model struct.
+//
+// Refresh tokens are opaque random strings handed out to clients: only
+// their SHA-256 hash is stored. Every time a refresh token is used, it is
+// revoked and replaced by a new one of the same "family", so that the reuse
+// of a rotated token (which hints at a leak) can be detected.
+type RefreshToken struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	UserID    uuid.UUID  `json:"-" db:"user_id"`
+	FamilyID  uuid.UUID  `json:"-" db:"family_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt nulls.Time `json:"revoked_at" db:"revoked_at"`
+}
+
+// String is not required by pop and may be deleted
+func (t RefreshToken) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// hashToken computes the hash under which a refresh token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewRefreshToken generates a new refresh token for given user, returning
+// both the model (which still needs to be saved) and the clear token.
+// A new token family is started if familyID is uuid.Nil.
+func NewRefreshToken(userID, familyID uuid.UUID, ttl time.Duration) (*RefreshToken, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	if familyID == uuid.Nil {
+		var err error
+		if familyID, err = uuid.NewV4(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return &RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}, token, nil
+}
+
+// FindRefreshToken looks up a refresh token from its clear value
+func FindRefreshToken(tx *pop.Connection, token string) (*RefreshToken, error) {
+	rt := &RefreshToken{}
+	if err := tx.Where("token_hash = ?", hashToken(token)).First(rt); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// Create saves a newly created refresh token into the database
+func (t *RefreshToken) Create(tx *pop.Connection) error {
+	return tx.Create(t)
+}
+
+// Expired tells whether the refresh token has expired
+func (t *RefreshToken) Expired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// Revoke revokes a single refresh token
+func (t *RefreshToken) Revoke(tx *pop.Connection) error {
+	t.RevokedAt = nulls.NewTime(time.Now())
+	return tx.Update(t)
+}
+
+// Rotate revokes the refresh token and replaces it with a new one of the
+// same family. The token is only revoked if it wasn't already, which is
+// checked by the UPDATE query itself: of two concurrent rotations, only one
+// succeeds, and the other gets ErrTokenRevoked.
+func (t *RefreshToken) Rotate(tx *pop.Connection, ttl time.Duration) (*RefreshToken, string, error) {
+	now := time.Now()
+	count, err := tx.RawQuery(
+		`UPDATE refresh_tokens SET revoked_at = ?, updated_at = ?
+		WHERE id = ? AND revoked_at IS NULL`,
+		now, now, t.ID,
+	).ExecWithCount()
+	if err != nil {
+		return nil, "", err
+	}
+	if count == 0 {
+		return nil, "", ErrTokenRevoked
+	}
+	t.RevokedAt = nulls.NewTime(now)
+	t.UpdatedAt = now
+
+	next, token, err := NewRefreshToken(t.UserID, t.FamilyID, ttl)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := next.Create(tx); err != nil {
+		return nil, "", err
+	}
+	return next, token, nil
+}
+
+// RevokeTokenFamily revokes all the refresh tokens of a family
+func RevokeTokenFamily(tx *pop.Connection, familyID uuid.UUID) error {
+	now := time.Now()
+	return tx.RawQuery(
+		`UPDATE refresh_tokens SET revoked_at = ?, updated_at = ?
+		WHERE family_id = ? AND revoked_at IS NULL`,
+		now, now, familyID,
+	).Exec()
+}
+
+// RevokeAccessToken adds an access token, identified by its "jti" claim, to
+// the revocation list until it expires.
+func RevokeAccessToken(tx *pop.Connection, jti string, expiresAt time.Time) error {
+	// Expired tokens are rejected anyway: no need to remember them.
+	err := tx.RawQuery(
+		"DELETE FROM revoked_tokens WHERE expires_at < ?", time.Now(),
+	).Exec()
+	if err != nil {
+		return err
+	}
+	return tx.RawQuery(
+		`INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?)
+		ON CONFLICT (jti) DO NOTHING`,
+		jti, expiresAt,
+	).Exec()
+}
+
+// IsAccessTokenRevoked tells whether an access token has been revoked
+func IsAccessTokenRevoked(tx *pop.Connection, jti string) (bool, error) {
+	return tx.Where("jti = ?", jti).Exists("revoked_tokens")
+}
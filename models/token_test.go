@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func (ms *ModelSuite) Test_RefreshToken_Create() {
+	user := ms.createRandomUser()
+
+	rt, token, err := NewRefreshToken(user.ID, uuid.Nil, time.Hour)
+	ms.NoError(err)
+	ms.NotEmpty(token)
+	ms.NotEqual(token, rt.TokenHash)
+	ms.NotEqual(uuid.Nil, rt.FamilyID)
+	ms.NoError(rt.Create(ms.DB))
+
+	found, err := FindRefreshToken(ms.DB, token)
+	ms.NoError(err)
+	ms.Equal(rt.ID, found.ID)
+	ms.False(found.Expired())
+	ms.False(found.RevokedAt.Valid)
+
+	_, err = FindRefreshToken(ms.DB, "not-a-token")
+	ms.Error(err)
+}
+
+func (ms *ModelSuite) Test_RefreshToken_Rotate() {
+	user := ms.createRandomUser()
+
+	rt, _, err := NewRefreshToken(user.ID, uuid.Nil, time.Hour)
+	ms.NoError(err)
+	ms.NoError(rt.Create(ms.DB))
+
+	stale := *rt
+	next, token, err := rt.Rotate(ms.DB, time.Hour)
+	ms.NoError(err)
+	ms.True(rt.RevokedAt.Valid)
+	ms.Equal(rt.FamilyID, next.FamilyID)
+
+	// A stale copy of the token, loaded by a concurrent request, can't be
+	// rotated again.
+	_, _, err = stale.Rotate(ms.DB, time.Hour)
+	ms.Equal(ErrTokenRevoked, err)
+
+	err = RevokeTokenFamily(ms.DB, rt.FamilyID)
+	ms.NoError(err)
+
+	found, err := FindRefreshToken(ms.DB, token)
+	ms.NoError(err)
+	ms.True(found.RevokedAt.Valid)
+}
+
+func (ms *ModelSuite) Test_AccessToken_Revoke() {
+	revoked, err := IsAccessTokenRevoked(ms.DB, "some-jti")
+	ms.NoError(err)
+	ms.False(revoked)
+
+	err = RevokeAccessToken(ms.DB, "some-jti", time.Now().Add(time.Hour))
+	ms.NoError(err)
+
+	// Revoking twice is harmless
+	err = RevokeAccessToken(ms.DB, "some-jti", time.Now().Add(time.Hour))
+	ms.NoError(err)
+
+	revoked, err = IsAccessTokenRevoked(ms.DB, "some-jti")
+	ms.NoError(err)
+	ms.True(revoked)
+}
@@ -2,41 +2,62 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop"
 	"github.com/gobuffalo/validate"
 	"github.com/gobuffalo/validate/validators"
 	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// MinPasswordLength is the minimal length of a user's password
+const MinPasswordLength = 8
+
+// ErrStaleUser is returned when updating a user that was modified by someone
+// else since it was loaded.
+var ErrStaleUser = errors.New("This user was modified in the meantime")
+
 // "Light" user model accepted for user creation and modification
 type LightUser struct {
-	Login string `json:"login"` // Unique login
-	Info  string `json:"info"`  // Optional user information
-	Admin bool   `json:"admin"` // User has admin credentials
+	Login    string `json:"login"`    // Unique login
+	Password string `json:"password"` // Password (mandatory on creation)
+	Info     string `json:"info"`     // Optional user information
+	Admin    bool   `json:"admin"`    // User has admin credentials
 }
 
 // User model struct
 type User struct {
-	ID          uuid.UUID      `json:"id" db:"id" fake:"skip"`
-	CreatedAt   time.Time      `json:"created_at" db:"created_at" fake:"skip"`
-	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at" fake:"skip"`
-	Login       string         `json:"login" db:"login" fake:"{person.first}{person.last}"`
-	Info        string         `json:"info" db:"info" fake:"{hipster.word}"`
-	Admin       bool           `json:"admin" db:"admin" fake:"skip"`
-	Friends     Users          `json:"friends,omitempty" db:"-"`
-	OutRequests FriendRequests `json:"pending_requests,omitempty" db:"-" order_by:"created_at desc"`
-	InRequests  FriendRequests `json:"incoming_requests,omitempty" db:"-" order_by:"created_at desc"`
-	Reports     Reports        `json:"reports,omitempty" db:"-" order_by:"created_at desc"`
+	ID           uuid.UUID      `json:"id" db:"id" fake:"skip"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at" fake:"skip"`
+	UpdatedAt    time.Time      `json:"updated_at" db:"updated_at" fake:"skip"`
+	Login        string         `json:"login" db:"login" fake:"{person.first}{person.last}"`
+	Info         string         `json:"info" db:"info" fake:"{hipster.word}"`
+	Admin        bool           `json:"admin" db:"admin" fake:"skip"`
+	Password     string         `json:"-" db:"-" fake:"skip"`
+	PasswordHash string         `json:"-" db:"password_hash" fake:"skip"`
+	TokenVersion int            `json:"-" db:"token_version" fake:"skip"`
+	FlaggedAt    nulls.Time     `json:"-" db:"flagged_at" fake:"skip"`
+	LockVersion  int            `json:"-" db:"lock_version" fake:"skip"` // Incremented on each update
+	Roles        []Role         `json:"roles,omitempty" db:"-" fake:"skip"`
+	MutualCount  *int           `json:"mutual_friend_count,omitempty" db:"-" fake:"skip"`
+	Friends      Users          `json:"friends,omitempty" db:"-"`
+	OutRequests  FriendRequests `json:"pending_requests,omitempty" db:"-" order_by:"created_at desc"`
+	InRequests   FriendRequests `json:"incoming_requests,omitempty" db:"-" order_by:"created_at desc"`
+	Reports      Reports        `json:"reports,omitempty" db:"-" order_by:"created_at desc"`
+	Sanctions    Sanctions      `json:"sanctions,omitempty" db:"-" fake:"skip"`
 }
 
 // UserFromLight creates a User model from its "light" version
 func UserFromLight(light *LightUser) *User {
 	return &User{
-		Login: light.Login,
-		Info:  light.Info,
-		Admin: light.Admin,
+		Login:    light.Login,
+		Password: light.Password,
+		Info:     light.Info,
+		Admin:    light.Admin,
 	}
 }
 
@@ -61,6 +82,12 @@ func (u *User) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
 	var err error
 	return validate.Validate(
 		&validators.StringIsPresent{Field: u.Login, Name: "Login"},
+		&validators.StringLengthInRange{
+			Field:   u.Password,
+			Name:    "Password",
+			Min:     MinPasswordLength,
+			Message: fmt.Sprintf("Password must be at least %d characters long", MinPasswordLength),
+		},
 		&validators.FuncValidator{
 			Field:   u.Login,
 			Name:    "Login",
@@ -104,12 +131,65 @@ func (u *User) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
 
 // Create saves a newly created user into the database
 func (u *User) Create(tx *pop.Connection) (*validate.Errors, error) {
+	if u.Password != "" {
+		if err := u.SetPassword(u.Password); err != nil {
+			return validate.NewErrors(), err
+		}
+	}
 	return tx.ValidateAndCreate(u)
 }
 
-// Update updates user information in the database
+// SetPassword hashes given password and stores it in the PasswordHash field.
+// The user still needs to be saved afterwards.
+func (u *User) SetPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = string(hash)
+	return nil
+}
+
+// CheckPassword tells whether given password matches the user's
+func (u *User) CheckPassword(password string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
+	return err == nil
+}
+
+// Update updates user information in the database.
+// Changing a user's privileges invalidates all the auth tokens that were
+// issued before.
+//
+// Updates are optimistically locked: ErrStaleUser is returned if the user
+// was updated by someone else since it was loaded. The stored row is locked
+// until the end of the transaction, so concurrent updates can't slip in
+// between the check and the update.
+//
+// The columns that are maintained by other means (password hash, token
+// version, flag) are taken from the stored row, as they are changed without
+// bumping the version.
 func (u *User) Update(tx *pop.Connection) (*validate.Errors, error) {
-	return tx.ValidateAndUpdate(u)
+	stored := &User{}
+	err := tx.RawQuery("SELECT * FROM users WHERE id = ? FOR UPDATE", u.ID).First(stored)
+	if err != nil {
+		return validate.NewErrors(), err
+	}
+	if stored.LockVersion != u.LockVersion {
+		return validate.NewErrors(), ErrStaleUser
+	}
+	u.PasswordHash = stored.PasswordHash
+	u.TokenVersion = stored.TokenVersion
+	u.FlaggedAt = stored.FlaggedAt
+	if u.Admin != stored.Admin {
+		u.TokenVersion++
+	}
+
+	u.LockVersion++
+	verrs, err := tx.ValidateAndUpdate(u)
+	if err != nil || verrs.HasAny() {
+		u.LockVersion--
+	}
+	return verrs, err
 }
 
 // SendRequest sends a friend request to given user
@@ -133,25 +213,91 @@ func (u *User) SendRequest(tx *pop.Connection, to *User, msg string) (*FriendReq
 
 // FetchFriends looks up a user's friends and fills its Friends list
 func (u *User) FetchFriends(tx *pop.Connection) error {
-	q := tx.Where("friendships.user_id = ?", u.ID)
+	return FriendsQuery(tx.Q(), u.ID).All(&u.Friends)
+}
+
+// FriendsQuery narrows given query down to a user's friends, most recent
+// friendships first.
+func FriendsQuery(q *pop.Query, userID uuid.UUID) *pop.Query {
+	q = q.Where("friendships.user_id = ?", userID)
 	q = q.InnerJoin("friendships", "users.id = friendships.friend_id")
-	q = q.Order("friendships.created_at desc")
-	return q.All(&u.Friends)
+	return q.Order("friendships.created_at desc, users.id desc")
+}
+
+// FetchFriendsPage looks up a page of a user's friends (most recent
+// friendships first) and fills its Friends list. The cursors of the
+// previous and next pages are returned, if any.
+func (u *User) FetchFriendsPage(tx *pop.Connection, p *Page) (prev, next *Cursor, err error) {
+	fs := []Friendship{}
+	q := p.Apply(tx.Where("user_id = ?", u.ID), "created_at", "friend_id", true)
+	if err := q.All(&fs); err != nil {
+		return nil, nil, err
+	}
+	prev, next = p.Trim(&fs, func(i int) Cursor {
+		return Cursor{CreatedAt: fs[i].CreatedAt, ID: fs[i].FriendID}
+	})
+
+	u.Friends = make(Users, 0, len(fs))
+	if len(fs) == 0 {
+		return prev, next, nil
+	}
+	ids := make([]interface{}, len(fs))
+	for i, f := range fs {
+		ids[i] = f.FriendID
+	}
+	friends := Users{}
+	if err := tx.Where("id IN (?)", ids...).All(&friends); err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[uuid.UUID]User, len(friends))
+	for _, f := range friends {
+		byID[f.ID] = f
+	}
+	for _, f := range fs {
+		u.Friends = append(u.Friends, byID[f.FriendID])
+	}
+	return prev, next, nil
+}
+
+// MutualFriendsQuery narrows given query down to the friends two users have
+// in common.
+func MutualFriendsQuery(q *pop.Query, userID, otherID uuid.UUID) *pop.Query {
+	q = q.InnerJoin("friendships AS mine", "mine.friend_id = users.id AND mine.user_id = ?", userID)
+	q = q.InnerJoin("friendships AS theirs", "theirs.friend_id = users.id AND theirs.user_id = ?", otherID)
+	return q.Order("users.login, users.id")
+}
+
+// FetchMutualCount counts the friends a user has in common with another,
+// and fills its MutualCount field.
+func (u *User) FetchMutualCount(tx *pop.Connection, otherID uuid.UUID) error {
+	count := &rowCount{}
+	err := tx.RawQuery(
+		`SELECT COUNT(*) AS count
+		FROM friendships AS mine
+		INNER JOIN friendships AS theirs ON theirs.friend_id = mine.friend_id
+		WHERE mine.user_id = ? AND theirs.user_id = ?`,
+		otherID, u.ID,
+	).First(count)
+	if err != nil {
+		return err
+	}
+	u.MutualCount = &count.Count
+	return nil
 }
 
-// FetchOutRequests looks up a user's outgoing friend requests
+// FetchOutRequests looks up a user's pending outgoing friend requests
 func (u *User) FetchOutRequests(tx *pop.Connection) error {
-	q := tx.Eager("To").Where("from_id = ?", u.ID)
+	q := tx.Eager("To").Where("from_id = ? AND status = ?", u.ID, RequestPending)
 	return q.All(&u.OutRequests)
 }
 
-// FetchInRequests looks up a user's incoming friend requests
+// FetchInRequests looks up a user's pending incoming friend requests
 func (u *User) FetchInRequests(tx *pop.Connection) error {
-	q := tx.Eager("From").Where("to_id = ?", u.ID)
+	q := tx.Eager("From").Where("to_id = ? AND status = ?", u.ID, RequestPending)
 	return q.All(&u.InRequests)
 }
 
-// FetchRequests looks up a user's incoming and outgoing friend requests
+// FetchRequests looks up a user's pending incoming and outgoing friend requests
 func (u *User) FetchRequests(tx *pop.Connection) error {
 	if err := u.FetchInRequests(tx); err != nil {
 		return err
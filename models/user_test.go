@@ -2,12 +2,14 @@ package models
 
 import (
 	"github.com/brianvoe/gofakeit"
+	"github.com/gobuffalo/envy"
 )
 
 func (ms *ModelSuite) createRandomUser() *User {
 	u := &User{}
 	gofakeit.Struct(u)
-	verrs, err := ms.DB.ValidateAndCreate(u)
+	u.Password = gofakeit.Password(true, true, true, false, false, 12)
+	verrs, err := u.Create(ms.DB)
 	ms.NoError(err)
 	ms.Falsef(verrs.HasAny(), verrs.String())
 	return u
@@ -19,14 +21,17 @@ func (ms *ModelSuite) Test_User_Create() {
 	ms.Equal(0, count)
 
 	u := &User{
-		Login: "toto",
-		Info:  "Toto's information",
+		Login:    "toto",
+		Password: "correct horse",
+		Info:     "Toto's information",
 	}
 
 	verrs, err := u.Create(ms.DB)
 	ms.NoError(err)
 	ms.False(verrs.HasAny())
 	ms.NotZero(u.ID)
+	ms.NotEmpty(u.PasswordHash)
+	ms.NotEqual(u.Password, u.PasswordHash)
 
 	count, err = ms.DB.Count("users")
 	ms.NoError(err)
@@ -39,7 +44,8 @@ func (ms *ModelSuite) Test_User_Create_ValidationError() {
 	ms.Equal(0, count)
 
 	u := &User{
-		Info: "Toto's information",
+		Info:     "Toto's information",
+		Password: "correct horse",
 	}
 
 	verrs, err := u.Create(ms.DB)
@@ -57,7 +63,8 @@ func (ms *ModelSuite) Test_User_Create_UserExists() {
 	ms.Equal(0, count)
 
 	u := &User{
-		Login: "toto",
+		Login:    "toto",
+		Password: "correct horse",
 	}
 
 	verrs, err := u.Create(ms.DB)
@@ -66,7 +73,8 @@ func (ms *ModelSuite) Test_User_Create_UserExists() {
 	ms.NotZero(u.ID)
 
 	u = &User{
-		Login: "toto",
+		Login:    "toto",
+		Password: "battery staple",
 	}
 
 	verrs, err = u.Create(ms.DB)
@@ -77,3 +85,131 @@ func (ms *ModelSuite) Test_User_Create_UserExists() {
 	ms.NoError(err)
 	ms.Equal(1, count)
 }
+
+func (ms *ModelSuite) Test_User_Create_ShortPassword() {
+	u := &User{
+		Login:    "toto",
+		Password: "short",
+	}
+
+	verrs, err := u.Create(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	u.Password = ""
+	verrs, err = u.Create(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	count, err := ms.DB.Count("users")
+	ms.NoError(err)
+	ms.Equal(0, count)
+}
+
+func (ms *ModelSuite) Test_User_CheckPassword() {
+	u := &User{
+		Login:    "toto",
+		Password: "correct horse",
+	}
+
+	verrs, err := u.Create(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	stored := &User{}
+	err = ms.DB.Find(stored, u.ID)
+	ms.NoError(err)
+	ms.Empty(stored.Password)
+	ms.True(stored.CheckPassword("correct horse"))
+	ms.False(stored.CheckPassword("battery staple"))
+	ms.False(stored.CheckPassword(""))
+
+	// The password must never leak through JSON
+	ms.NotContains(stored.String(), stored.PasswordHash)
+}
+
+func (ms *ModelSuite) Test_User_Update_TokenVersion() {
+	u := ms.createRandomUser()
+	ms.Equal(0, u.TokenVersion)
+
+	// Regular updates don't change the token version
+	u.Info = "Some new info"
+	verrs, err := u.Update(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.Equal(0, u.TokenVersion)
+
+	// Privilege changes do
+	u.Admin = true
+	verrs, err = u.Update(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.Equal(1, u.TokenVersion)
+
+	stored := &User{}
+	err = ms.DB.Find(stored, u.ID)
+	ms.NoError(err)
+	ms.Equal(1, stored.TokenVersion)
+}
+
+func (ms *ModelSuite) Test_User_Update_Stale() {
+	u := ms.createRandomUser()
+	other := ms.createRandomUser()
+
+	mine := &User{}
+	ms.NoError(ms.DB.Find(mine, u.ID))
+	theirs := &User{}
+	ms.NoError(ms.DB.Find(theirs, u.ID))
+
+	mine.Info = "Mine"
+	verrs, err := mine.Update(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.Equal(1, mine.LockVersion)
+
+	// The other copy is outdated
+	theirs.Info = "Theirs"
+	_, err = theirs.Update(ms.DB)
+	ms.Equal(ErrStaleUser, err)
+
+	stored := &User{}
+	ms.NoError(ms.DB.Find(stored, u.ID))
+	ms.Equal("Mine", stored.Info)
+	ms.Equal(1, stored.LockVersion)
+
+	// Failed updates don't change the version
+	mine.Login = other.Login
+	verrs, err = mine.Update(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+	ms.Equal(1, mine.LockVersion)
+}
+
+func (ms *ModelSuite) Test_User_Update_Preserves() {
+	envy.Set("ESCALATION_REPORT_THRESHOLD", "1")
+	defer envy.Set("ESCALATION_REPORT_THRESHOLD", "")
+
+	u := ms.createRandomUser()
+	loaded := &User{}
+	ms.NoError(ms.DB.Find(loaded, u.ID))
+
+	// The user gets flagged and their tokens revoked in the meantime, which
+	// doesn't change their version.
+	report := &Report{ByID: ms.createRandomUser().ID, AboutID: u.ID, Category: CategorySpam}
+	verrs, err := report.Create(ms.DB)
+	ms.NoError(err)
+	ms.Falsef(verrs.HasAny(), verrs.String())
+	ms.NoError(u.BumpTokenVersion(ms.DB))
+
+	loaded.Info = "Edited"
+	verrs, err = loaded.Update(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	stored := &User{}
+	ms.NoError(ms.DB.Find(stored, u.ID))
+	ms.Equal("Edited", stored.Info)
+	ms.True(stored.FlaggedAt.Valid)
+	ms.Equal(1, stored.TokenVersion)
+	ms.Equal(u.PasswordHash, stored.PasswordHash)
+}